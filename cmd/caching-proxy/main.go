@@ -54,7 +54,12 @@ func main() {
 	cacheConfig := cache.Config{
 		MaxSize:         cfg.CacheSize,
 		DefaultTTL:      cfg.CacheTTL,
-		CleanupInterval: 5 * time.Minute,
+		CleanupInterval: cfg.CleanupInterval,
+		Admission:       cfg.CacheAdmission,
+		Versions:        cfg.CacheVersions,
+		Namespace:       cfg.CacheNamespace,
+		Eviction:        cfg.CacheEviction,
+		StaleGrace:      cfg.StaleGrace,
 	}
 	cacheInstance := cache.New(cacheConfig)
 
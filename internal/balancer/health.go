@@ -0,0 +1,316 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cache-proxy/internal/logger"
+)
+
+// Health check defaults applied to zero settings
+const (
+	defaultProbeInterval      = 10 * time.Second
+	defaultProbeTimeout       = 2 * time.Second
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+	defaultEjectionTime       = 30 * time.Second
+)
+
+// HealthCheck configures active probes; an empty Path disables them
+type HealthCheck struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	ExpectedStatus     int // 0 accepts any 2xx or 3xx
+	HealthyThreshold   int // consecutive passes that restore a target
+	UnhealthyThreshold int // consecutive failures that remove a target
+}
+
+// OutlierDetection configures passive ejection of targets that keep failing
+// real requests; zero ConsecutiveErrors disables it
+type OutlierDetection struct {
+	ConsecutiveErrors int
+	EjectionTime      time.Duration
+}
+
+// TargetHealth reports whether a target is in rotation and why not
+type TargetHealth struct {
+	Healthy           bool       `json:"healthy"`
+	ProbeFailing      bool       `json:"probe_failing"`
+	ConsecutivePasses int        `json:"consecutive_passes"`
+	ConsecutiveFails  int        `json:"consecutive_fails"`
+	ConsecutiveErrors int        `json:"consecutive_errors"`
+	Ejections         int64      `json:"ejections"`
+	EjectedUntil      *time.Time `json:"ejected_until,omitempty"`
+	LastProbeAt       *time.Time `json:"last_probe_at,omitempty"`
+	LastProbeError    string     `json:"last_probe_error,omitempty"`
+}
+
+// targetHealth is a target's probe and outlier state
+type targetHealth struct {
+	mutex        sync.Mutex
+	probeFailing bool
+	passes       int
+	fails        int
+	errors       int
+	ejections    int64
+	ejectedUntil time.Time
+	lastProbeAt  *time.Time
+	lastError    string
+}
+
+// Healthy reports whether the target is passing probes and not ejected
+func (t *Target) Healthy() bool {
+	t.health.mutex.Lock()
+	defer t.health.mutex.Unlock()
+	return t.healthyLocked(time.Now())
+}
+
+// healthyLocked reports health at now; the caller holds the health mutex
+func (t *Target) healthyLocked(now time.Time) bool {
+	return !t.health.probeFailing && !now.Before(t.health.ejectedUntil)
+}
+
+// Health returns a snapshot of the target's health state
+func (t *Target) Health() TargetHealth {
+	t.health.mutex.Lock()
+	defer t.health.mutex.Unlock()
+
+	now := time.Now()
+	h := TargetHealth{
+		Healthy:           t.healthyLocked(now),
+		ProbeFailing:      t.health.probeFailing,
+		ConsecutivePasses: t.health.passes,
+		ConsecutiveFails:  t.health.fails,
+		ConsecutiveErrors: t.health.errors,
+		Ejections:         t.health.ejections,
+		LastProbeAt:       t.health.lastProbeAt,
+		LastProbeError:    t.health.lastError,
+	}
+	if now.Before(t.health.ejectedUntil) {
+		until := t.health.ejectedUntil
+		h.EjectedUntil = &until
+	}
+	return h
+}
+
+// recordProbe folds a probe result into the target's state and reports
+// whether it moved the target in or out of rotation
+func (t *Target) recordProbe(probeErr error, check HealthCheck) bool {
+	t.health.mutex.Lock()
+	defer t.health.mutex.Unlock()
+
+	now := time.Now()
+	t.health.lastProbeAt = &now
+	if probeErr != nil {
+		t.health.lastError = probeErr.Error()
+		t.health.passes = 0
+		t.health.fails++
+		if !t.health.probeFailing && t.health.fails >= check.UnhealthyThreshold {
+			t.health.probeFailing = true
+			return true
+		}
+		return false
+	}
+
+	t.health.lastError = ""
+	t.health.fails = 0
+	t.health.passes++
+	if t.health.probeFailing && t.health.passes >= check.HealthyThreshold {
+		t.health.probeFailing = false
+		return true
+	}
+	return false
+}
+
+// recordOutcome counts consecutive request failures and reports whether
+// this one ejected the target
+func (t *Target) recordOutcome(failed bool, outlier OutlierDetection) bool {
+	t.health.mutex.Lock()
+	defer t.health.mutex.Unlock()
+
+	if !failed {
+		t.health.errors = 0
+		return false
+	}
+	t.health.errors++
+	now := time.Now()
+	if t.health.errors < outlier.ConsecutiveErrors || now.Before(t.health.ejectedUntil) {
+		return false
+	}
+	t.health.errors = 0
+	t.health.ejections++
+	t.health.ejectedUntil = now.Add(outlier.EjectionTime)
+	return true
+}
+
+// Checker probes a pool's targets in the background and ejects targets that
+// keep failing real requests
+type Checker struct {
+	name    string
+	pool    *Pool
+	check   HealthCheck
+	outlier OutlierDetection
+	client  *http.Client
+	logger  logger.Logger
+	stop    chan struct{}
+	done    chan struct{}
+
+	mutex   sync.Mutex
+	started bool
+}
+
+// NewChecker creates a checker for the named upstream's pool; probes are
+// sent with client, which should not follow redirects
+func NewChecker(name string, pool *Pool, check HealthCheck, outlier OutlierDetection, client *http.Client, log logger.Logger) *Checker {
+	if check.Interval <= 0 {
+		check.Interval = defaultProbeInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultProbeTimeout
+	}
+	if check.Timeout > check.Interval {
+		check.Timeout = check.Interval
+	}
+	if check.HealthyThreshold <= 0 {
+		check.HealthyThreshold = defaultHealthyThreshold
+	}
+	if check.UnhealthyThreshold <= 0 {
+		check.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if outlier.EjectionTime <= 0 {
+		outlier.EjectionTime = defaultEjectionTime
+	}
+
+	return &Checker{
+		name:    name,
+		pool:    pool,
+		check:   check,
+		outlier: outlier,
+		client:  client,
+		logger:  log,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start begins probing when a health check path is configured
+func (c *Checker) Start() {
+	if c.check.Path == "" {
+		return
+	}
+	c.mutex.Lock()
+	c.started = true
+	c.mutex.Unlock()
+	go c.run()
+}
+
+// Stop halts probing
+func (c *Checker) Stop() {
+	c.mutex.Lock()
+	started := c.started
+	c.mutex.Unlock()
+	if !started {
+		return
+	}
+	close(c.stop)
+	<-c.done
+}
+
+// Observe records the outcome of a real request to t for outlier detection
+func (c *Checker) Observe(t *Target, failed bool) {
+	if c.outlier.ConsecutiveErrors <= 0 {
+		return
+	}
+	if t.recordOutcome(failed, c.outlier) {
+		c.logger.Warn().
+			Str("upstream", c.name).
+			Str("target", t.URL.String()).
+			Int("consecutive_errors", c.outlier.ConsecutiveErrors).
+			Dur("ejection_time", c.outlier.EjectionTime).
+			Msg("Upstream target ejected after consecutive errors")
+	}
+}
+
+// run probes every target on each tick until stopped
+func (c *Checker) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.probeAll()
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// probeAll probes every target concurrently
+func (c *Checker) probeAll() {
+	var wg sync.WaitGroup
+	for _, t := range c.pool.Targets() {
+		wg.Add(1)
+		go func(t *Target) {
+			defer wg.Done()
+			err := c.probe(t)
+			if !t.recordProbe(err, c.check) {
+				return
+			}
+			if err != nil {
+				c.logger.Warn().Err(err).Str("upstream", c.name).Str("target", t.URL.String()).Msg("Upstream target failed health checks, removed from rotation")
+			} else {
+				c.logger.Info().Str("upstream", c.name).Str("target", t.URL.String()).Msg("Upstream target passed health checks, restored to rotation")
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// probe sends one health check request to t
+func (c *Checker) probe(t *Target) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.check.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL(t.URL, c.check.Path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if c.check.ExpectedStatus != 0 {
+		if resp.StatusCode != c.check.ExpectedStatus {
+			return fmt.Errorf("unexpected status %d, want %d", resp.StatusCode, c.check.ExpectedStatus)
+		}
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeURL appends a health check path, which may carry a query, to base
+func probeURL(base *url.URL, path string) string {
+	u := *base
+	ref, err := url.Parse(path)
+	if err != nil {
+		ref = &url.URL{Path: path}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + ref.Path
+	u.RawPath = ""
+	u.RawQuery = ref.RawQuery
+	return u.String()
+}
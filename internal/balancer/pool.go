@@ -0,0 +1,242 @@
+package balancer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Load-balancing strategies
+const (
+	RoundRobin         = "round_robin"
+	WeightedRoundRobin = "weighted_round_robin"
+	LeastConnections   = "least_connections"
+	RandomTwoChoices   = "random_two_choices"
+	ConsistentHash     = "consistent_hash"
+)
+
+// ringReplicas is how many points each unit of weight places on the hash ring
+const ringReplicas = 100
+
+// ValidStrategy reports whether name is a known strategy
+func ValidStrategy(name string) bool {
+	switch name {
+	case RoundRobin, WeightedRoundRobin, LeastConnections, RandomTwoChoices, ConsistentHash:
+		return true
+	}
+	return false
+}
+
+// PoolStats is a snapshot of a pool and its targets
+type PoolStats struct {
+	Strategy string        `json:"strategy"`
+	Healthy  int           `json:"healthy"` // targets currently in rotation
+	Targets  []TargetStats `json:"targets"`
+}
+
+// ringPoint places a target on the consistent hash ring
+type ringPoint struct {
+	hash   uint32
+	target *Target
+}
+
+// Pool spreads requests across a set of targets using one strategy
+type Pool struct {
+	strategy string
+	targets  []*Target
+	next     atomic.Uint64
+
+	// Smooth weighted round-robin state
+	mutex   sync.Mutex
+	current []int
+
+	ring []ringPoint
+}
+
+// NewPool creates a pool; an empty strategy means round-robin
+func NewPool(strategy string, configs []TargetConfig) (*Pool, error) {
+	if strategy == "" {
+		strategy = RoundRobin
+	}
+	if !ValidStrategy(strategy) {
+		return nil, fmt.Errorf("unknown load-balancing strategy %q", strategy)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("pool has no targets")
+	}
+
+	p := &Pool{strategy: strategy, current: make([]int, len(configs))}
+	for _, tc := range configs {
+		parsed, err := url.Parse(tc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target URL %q: %w", tc.URL, err)
+		}
+		weight := tc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		p.targets = append(p.targets, &Target{URL: parsed, Weight: weight})
+	}
+
+	if strategy == ConsistentHash {
+		p.buildRing()
+	}
+	return p, nil
+}
+
+// Targets returns the pool's members
+func (p *Pool) Targets() []*Target {
+	return p.targets
+}
+
+// Stats returns a snapshot of every target
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{Strategy: p.strategy, Targets: make([]TargetStats, len(p.targets))}
+	for i, t := range p.targets {
+		stats.Targets[i] = t.Stats()
+		if stats.Targets[i].Health.Healthy {
+			stats.Healthy++
+		}
+	}
+	return stats
+}
+
+// Pick chooses a healthy target for a request, skipping any in exclude. The
+// key is used by consistent hashing so the same cache key keeps reaching the
+// same target. It returns nil when no healthy target remains.
+func (p *Pool) Pick(key string, exclude ...*Target) *Target {
+	candidates := p.candidates(exclude)
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	switch p.strategy {
+	case WeightedRoundRobin:
+		return p.pickWeighted(candidates)
+	case LeastConnections:
+		return p.pickLeastConnections(candidates)
+	case RandomTwoChoices:
+		return p.pickTwoChoices(candidates)
+	case ConsistentHash:
+		return p.pickHashed(key, candidates)
+	default:
+		return candidates[p.next.Add(1)%uint64(len(candidates))]
+	}
+}
+
+// candidates returns the healthy targets not in exclude
+func (p *Pool) candidates(exclude []*Target) []*Target {
+	candidates := make([]*Target, 0, len(p.targets))
+	for _, t := range p.targets {
+		if t.Healthy() && !containsTarget(exclude, t) {
+			candidates = append(candidates, t)
+		}
+	}
+	return candidates
+}
+
+// pickWeighted implements smooth weighted round-robin: every pick adds each
+// candidate's weight to its running score and the highest score wins,
+// paying back the total weight
+func (p *Pool) pickWeighted(candidates []*Target) *Target {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	total, best := 0, -1
+	for i, t := range p.targets {
+		if !containsTarget(candidates, t) {
+			continue
+		}
+		p.current[i] += t.Weight
+		total += t.Weight
+		if best < 0 || p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= total
+	return p.targets[best]
+}
+
+// pickLeastConnections picks the candidate with the fewest in-flight
+// requests per unit of weight, rotating the starting point to spread ties
+func (p *Pool) pickLeastConnections(candidates []*Target) *Target {
+	start := int(p.next.Add(1) % uint64(len(candidates)))
+	var best *Target
+	for i := range candidates {
+		t := candidates[(start+i)%len(candidates)]
+		if best == nil || t.InFlight()*int64(best.Weight) < best.InFlight()*int64(t.Weight) {
+			best = t
+		}
+	}
+	return best
+}
+
+// pickTwoChoices samples two distinct candidates and keeps the less loaded
+// one, falling back to lower latency on a tie
+func (p *Pool) pickTwoChoices(candidates []*Target) *Target {
+	i := rand.Intn(len(candidates))
+	j := rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := candidates[i], candidates[j]
+	if a.InFlight() != b.InFlight() {
+		if a.InFlight() < b.InFlight() {
+			return a
+		}
+		return b
+	}
+	if b.Latency() < a.Latency() {
+		return b
+	}
+	return a
+}
+
+// pickHashed walks the ring clockwise from the key's hash to the first
+// candidate
+func (p *Pool) pickHashed(key string, candidates []*Target) *Target {
+	h := hash32(key)
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	for i := 0; i < len(p.ring); i++ {
+		point := p.ring[(start+i)%len(p.ring)]
+		if containsTarget(candidates, point.target) {
+			return point.target
+		}
+	}
+	return candidates[0]
+}
+
+// buildRing places every target on the hash ring in proportion to its weight
+func (p *Pool) buildRing() {
+	for _, t := range p.targets {
+		for i := 0; i < ringReplicas*t.Weight; i++ {
+			p.ring = append(p.ring, ringPoint{hash: hash32(t.URL.String() + "#" + strconv.Itoa(i)), target: t})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+}
+
+// hash32 hashes a string onto the ring
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// containsTarget reports whether targets includes t
+func containsTarget(targets []*Target, t *Target) bool {
+	for _, candidate := range targets {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package balancer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestPool creates a pool over targets a, b, c... with the given weights
+func newTestPool(t *testing.T, strategy string, weights ...int) *Pool {
+	t.Helper()
+	configs := make([]TargetConfig, len(weights))
+	for i, weight := range weights {
+		configs[i] = TargetConfig{URL: fmt.Sprintf("http://%c.test", 'a'+i), Weight: weight}
+	}
+	pool, err := NewPool(strategy, configs)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	return pool
+}
+
+// picks counts how often each target is chosen over n picks
+func picks(pool *Pool, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[pool.Pick(fmt.Sprintf("key-%d", i)).URL.Host]++
+	}
+	return counts
+}
+
+// TestNewPool checks strategy validation and the round-robin default
+func TestNewPool(t *testing.T) {
+	for _, strategy := range []string{RoundRobin, WeightedRoundRobin, LeastConnections, RandomTwoChoices, ConsistentHash} {
+		if !ValidStrategy(strategy) {
+			t.Errorf("ValidStrategy(%q) = false", strategy)
+		}
+	}
+	if ValidStrategy("random") {
+		t.Error("ValidStrategy(random) = true")
+	}
+	if _, err := NewPool("random", []TargetConfig{{URL: "http://a.test"}}); err == nil {
+		t.Error("NewPool should reject an unknown strategy")
+	}
+	if _, err := NewPool(RoundRobin, nil); err == nil {
+		t.Error("NewPool should reject a pool without targets")
+	}
+	if pool := newTestPool(t, "", 1); pool.Stats().Strategy != RoundRobin {
+		t.Errorf("default strategy = %s, want round_robin", pool.Stats().Strategy)
+	}
+}
+
+// TestRoundRobin checks that picks rotate evenly and skip excluded targets
+func TestRoundRobin(t *testing.T) {
+	pool := newTestPool(t, RoundRobin, 1, 1, 1)
+	for host, count := range picks(pool, 9) {
+		if count != 3 {
+			t.Errorf("%s picked %d times, want 3", host, count)
+		}
+	}
+
+	excluded := pool.Targets()[0]
+	for i := 0; i < 6; i++ {
+		if pool.Pick("", excluded) == excluded {
+			t.Fatal("excluded target was picked")
+		}
+	}
+}
+
+// TestWeightedRoundRobin checks that picks follow the weights without
+// bunching the heaviest target together
+func TestWeightedRoundRobin(t *testing.T) {
+	pool := newTestPool(t, WeightedRoundRobin, 5, 1, 1)
+
+	var sequence string
+	for i := 0; i < 7; i++ {
+		sequence += pool.Pick("").URL.Host[:1]
+	}
+	if sequence != "aabacaa" {
+		t.Errorf("pick sequence = %s, want aabacaa", sequence)
+	}
+}
+
+// TestLeastConnections checks that the target with the fewest in-flight
+// requests per unit of weight wins
+func TestLeastConnections(t *testing.T) {
+	pool := newTestPool(t, LeastConnections, 4, 1)
+	a, b := pool.Targets()[0], pool.Targets()[1]
+
+	doneB := b.Start()
+	if got := pool.Pick(""); got != a {
+		t.Errorf("picked %s, want the idle target", got.URL.Host)
+	}
+
+	// Two requests on weight 4 are a lighter load than one on weight 1
+	doneA1, doneA2 := a.Start(), a.Start()
+	if got := pool.Pick(""); got != a {
+		t.Errorf("picked %s, want the heavier-weighted target", got.URL.Host)
+	}
+
+	doneB(time.Millisecond, false)
+	if got := pool.Pick(""); got != b {
+		t.Errorf("picked %s, want the target that finished its request", got.URL.Host)
+	}
+	doneA1(time.Millisecond, false)
+	doneA2(time.Millisecond, false)
+}
+
+// TestRandomTwoChoices checks that the busiest target is never picked, since
+// it always loses to the other sampled target
+func TestRandomTwoChoices(t *testing.T) {
+	pool := newTestPool(t, RandomTwoChoices, 1, 1, 1)
+	busy := pool.Targets()[2]
+	for i := 0; i < 3; i++ {
+		defer busy.Start()(time.Millisecond, false)
+	}
+
+	counts := picks(pool, 300)
+	if counts[busy.URL.Host] != 0 {
+		t.Errorf("busy target picked %d times, want 0", counts[busy.URL.Host])
+	}
+	if counts["a.test"] == 0 || counts["b.test"] == 0 {
+		t.Errorf("picks = %v, want both idle targets used", counts)
+	}
+}
+
+// TestConsistentHash checks that a key keeps its target, that every target
+// takes a share of keys, and that excluding a target only moves its own keys
+func TestConsistentHash(t *testing.T) {
+	pool := newTestPool(t, ConsistentHash, 1, 1, 1)
+
+	owners := make(map[string]*Target)
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owners[key] = pool.Pick(key)
+		counts[owners[key].URL.Host]++
+		if pool.Pick(key) != owners[key] {
+			t.Fatalf("key %s moved between picks", key)
+		}
+	}
+	for _, target := range pool.Targets() {
+		if counts[target.URL.Host] < 200 {
+			t.Errorf("picks = %v, want every target to take a share", counts)
+		}
+	}
+
+	excluded := pool.Targets()[0]
+	for key, owner := range owners {
+		got := pool.Pick(key, excluded)
+		if got == excluded {
+			t.Fatalf("key %s picked the excluded target", key)
+		}
+		if owner != excluded && got != owner {
+			t.Fatalf("key %s moved from %s though its target is still available", key, owner.URL.Host)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package balancer
+
+import (
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// latencyWeight is the weight of the newest sample in a target's latency
+// moving average
+const latencyWeight = 0.2
+
+// TargetConfig describes one member of a pool
+type TargetConfig struct {
+	URL    string
+	Weight int // defaults to 1
+}
+
+// TargetStats is a snapshot of a target's load and performance
+type TargetStats struct {
+	URL      string        `json:"url"`
+	Weight   int           `json:"weight"`
+	InFlight int64         `json:"in_flight"`
+	Requests int64         `json:"requests"`
+	Failures int64         `json:"failures"`
+	Latency  time.Duration `json:"latency"` // exponentially weighted moving average
+	Health   TargetHealth  `json:"health"`
+}
+
+// Target is a single upstream server in a pool
+type Target struct {
+	URL    *url.URL
+	Weight int
+
+	inFlight atomic.Int64
+	requests atomic.Int64
+	failures atomic.Int64
+	latency  atomic.Int64 // nanoseconds, moving average
+
+	health targetHealth
+}
+
+// Start records a request being sent to the target and returns a function
+// that must be called with the outcome once it completes
+func (t *Target) Start() func(latency time.Duration, failed bool) {
+	t.inFlight.Add(1)
+	return func(latency time.Duration, failed bool) {
+		t.inFlight.Add(-1)
+		t.requests.Add(1)
+		if failed {
+			t.failures.Add(1)
+		}
+		t.observeLatency(latency)
+	}
+}
+
+// InFlight returns how many requests are currently outstanding
+func (t *Target) InFlight() int64 {
+	return t.inFlight.Load()
+}
+
+// Latency returns the moving average response time
+func (t *Target) Latency() time.Duration {
+	return time.Duration(t.latency.Load())
+}
+
+// Stats returns a snapshot of the target's counters
+func (t *Target) Stats() TargetStats {
+	return TargetStats{
+		URL:      t.URL.String(),
+		Weight:   t.Weight,
+		InFlight: t.inFlight.Load(),
+		Requests: t.requests.Load(),
+		Failures: t.failures.Load(),
+		Latency:  t.Latency(),
+		Health:   t.Health(),
+	}
+}
+
+// observeLatency folds a sample into the moving average
+func (t *Target) observeLatency(latency time.Duration) {
+	for {
+		old := t.latency.Load()
+		updated := int64(latency)
+		if old != 0 {
+			updated = old + int64(latencyWeight*float64(int64(latency)-old))
+		}
+		if t.latency.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
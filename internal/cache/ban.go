@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ban is a Varnish-style invalidation rule. Entries stored before the ban was
+// added that match every condition are treated as misses on lookup.
+type Ban struct {
+	Expression string    `json:"expression"`
+	CreatedAt  time.Time `json:"created_at"`
+	conditions []banCondition
+}
+
+// banCondition is a single "field operator value" clause of a ban expression
+type banCondition struct {
+	field    string
+	operator string
+	value    string
+	pattern  *regexp.Regexp
+}
+
+// ParseBan parses a ban expression such as
+// `req.url ~ ^/products && obj.http.content-type ~ json`.
+// Supported fields are req.url, obj.status and obj.http.<header>;
+// supported operators are ==, !=, ~ and !~.
+func ParseBan(expression string) (*Ban, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, fmt.Errorf("empty ban expression")
+	}
+
+	ban := &Ban{Expression: expression}
+	for _, clause := range strings.Split(expression, "&&") {
+		clause = strings.TrimSpace(clause)
+		parts := strings.Fields(clause)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid ban clause: %q", clause)
+		}
+
+		// The value is everything after the operator so patterns may contain spaces
+		value := strings.TrimSpace(clause[len(parts[0]):])
+		value = strings.TrimSpace(value[len(parts[1]):])
+
+		cond := banCondition{
+			field:    strings.ToLower(parts[0]),
+			operator: parts[1],
+			value:    unquote(value),
+		}
+
+		if cond.field != "req.url" && cond.field != "obj.status" && !strings.HasPrefix(cond.field, "obj.http.") {
+			return nil, fmt.Errorf("unsupported ban field: %s", parts[0])
+		}
+
+		switch cond.operator {
+		case "==", "!=":
+		case "~", "!~":
+			pattern, err := regexp.Compile(cond.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ban pattern %q: %w", cond.value, err)
+			}
+			cond.pattern = pattern
+		default:
+			return nil, fmt.Errorf("unsupported ban operator: %s", cond.operator)
+		}
+
+		ban.conditions = append(ban.conditions, cond)
+	}
+
+	return ban, nil
+}
+
+// Matches reports whether the entry satisfies every condition of the ban
+func (b *Ban) Matches(entry *Entry) bool {
+	for _, cond := range b.conditions {
+		if !cond.matches(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches evaluates a single condition against an entry
+func (c banCondition) matches(entry *Entry) bool {
+	var subject string
+	switch {
+	case c.field == "req.url":
+		subject = entry.URL
+	case c.field == "obj.status":
+		subject = strconv.Itoa(entry.Status)
+	default:
+		subject = entry.Headers.Get(strings.TrimPrefix(c.field, "obj.http."))
+	}
+
+	switch c.operator {
+	case "==":
+		return subject == c.value
+	case "!=":
+		return subject != c.value
+	case "~":
+		return c.pattern.MatchString(subject)
+	default:
+		return !c.pattern.MatchString(subject)
+	}
+}
+
+// unquote strips a single pair of surrounding double quotes
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// banList holds active bans in creation order
+type banList struct {
+	mutex sync.RWMutex
+	bans  []*Ban
+}
+
+// add inserts a ban in creation order, stamping it with the current time
+// unless it already has one. A stamped ban is never modified, so the same
+// ban can be shared by several caches.
+func (l *banList) add(ban *Ban) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	i := len(l.bans)
+	for i > 0 && l.bans[i-1].CreatedAt.After(ban.CreatedAt) {
+		i--
+	}
+	l.bans = append(l.bans, nil)
+	copy(l.bans[i+1:], l.bans[i:])
+	l.bans[i] = ban
+}
+
+// banned reports whether any ban newer than the entry matches it
+func (l *banList) banned(entry *Entry) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for i := len(l.bans) - 1; i >= 0; i-- {
+		ban := l.bans[i]
+		if !ban.CreatedAt.After(entry.CreatedAt) {
+			break
+		}
+		if ban.Matches(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune drops bans that predate every cached entry and can no longer match
+func (l *banList) prune(oldest time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	i := 0
+	for i < len(l.bans) && !l.bans[i].CreatedAt.After(oldest) {
+		i++
+	}
+	l.bans = l.bans[i:]
+}
+
+// list returns a copy of the active bans
+func (l *banList) list() []*Ban {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	bans := make([]*Ban, len(l.bans))
+	copy(bans, l.bans)
+	return bans
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestParseBan covers accepted and rejected ban expressions
+func TestParseBan(t *testing.T) {
+	tests := []struct {
+		expression string
+		conditions int
+		wantErr    bool
+	}{
+		{"req.url ~ ^/products", 1, false},
+		{"req.url == /index.html", 1, false},
+		{"obj.status != 200", 1, false},
+		{`obj.http.content-type ~ "application/json"`, 1, false},
+		{"req.url ~ ^/a && obj.status == 404", 2, false},
+		{"req.url ~ a b", 1, false},
+		{"", 0, true},
+		{"req.url", 0, true},
+		{"req.host == example.com", 0, true},
+		{"req.url <> /x", 0, true},
+		{"req.url ~ (", 0, true},
+		{"req.url ~ ^/a && obj.status", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			ban, err := ParseBan(tt.expression)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseBan(%q) succeeded, want error", tt.expression)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBan(%q): %v", tt.expression, err)
+			}
+			if len(ban.conditions) != tt.conditions {
+				t.Errorf("got %d conditions, want %d", len(ban.conditions), tt.conditions)
+			}
+		})
+	}
+}
+
+// TestBanMatches evaluates each field and operator against an entry
+func TestBanMatches(t *testing.T) {
+	entry := &Entry{
+		URL:     "/products/42?color=red",
+		Status:  http.StatusOK,
+		Headers: http.Header{"Content-Type": []string{"application/json"}},
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"req.url ~ ^/products", true},
+		{"req.url ~ ^/orders", false},
+		{"req.url !~ ^/orders", true},
+		{"req.url == /products/42?color=red", true},
+		{"req.url != /products/42?color=red", false},
+		{"obj.status == 200", true},
+		{"obj.status == 404", false},
+		{"obj.http.content-type ~ json", true},
+		{`obj.http.Content-Type == "application/json"`, true},
+		{"obj.http.x-missing == value", false},
+		{"req.url ~ ^/products && obj.status == 200", true},
+		{"req.url ~ ^/products && obj.status == 500", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			ban, err := ParseBan(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseBan(%q): %v", tt.expression, err)
+			}
+			if got := ban.Matches(entry); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBanListOnlyAffectsOlderEntries checks that a ban hides entries stored
+// before it but not those stored after
+func TestBanListOnlyAffectsOlderEntries(t *testing.T) {
+	ban, err := ParseBan("req.url ~ ^/")
+	if err != nil {
+		t.Fatalf("ParseBan: %v", err)
+	}
+
+	older := &Entry{URL: "/a", CreatedAt: time.Now().Add(-time.Minute)}
+	var bans banList
+	bans.add(ban)
+	newer := &Entry{URL: "/a", CreatedAt: time.Now().Add(time.Millisecond)}
+
+	if !bans.banned(older) {
+		t.Error("entry stored before the ban should be banned")
+	}
+	if bans.banned(newer) {
+		t.Error("entry stored after the ban should not be banned")
+	}
+}
+
+// TestPromoteRechecksBans checks that a version stored before a matching ban
+// can't be promoted past it
+func TestPromoteRechecksBans(t *testing.T) {
+	c := New(Config{MaxSize: 10, Versions: 3}).(*InMemoryCache)
+	defer c.Close()
+
+	if err := c.Set("key", &Entry{URL: "/old", TTL: time.Hour}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	oldVersion := c.data["key"].Version()
+
+	ban, err := ParseBan("req.url == /old")
+	if err != nil {
+		t.Fatalf("ParseBan: %v", err)
+	}
+	c.Ban(ban)
+	time.Sleep(time.Millisecond)
+	if err := c.Set("key", &Entry{URL: "/new", TTL: time.Hour}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if err := c.Promote("key", oldVersion, false); !errors.Is(err, ErrVersionBanned) {
+		t.Fatalf("Promote banned version: err = %v, want ErrVersionBanned", err)
+	}
+	if entry, ok := c.Get("key"); !ok || entry.URL != "/new" {
+		t.Fatal("current entry should be unchanged after a refused promotion")
+	}
+}
+
+// TestSharedBan checks that a stamped ban added to several caches keeps its
+// time, and that out-of-order bans are kept in creation order
+func TestSharedBan(t *testing.T) {
+	first := New(Config{MaxSize: 10}).(*InMemoryCache)
+	defer first.Close()
+	second := New(Config{MaxSize: 10}).(*InMemoryCache)
+	defer second.Close()
+
+	if err := first.Set("key", &Entry{URL: "/a", TTL: time.Hour}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	ban, err := ParseBan("req.url ~ ^/a")
+	if err != nil {
+		t.Fatalf("ParseBan: %v", err)
+	}
+	stamp := time.Now()
+	ban.CreatedAt = stamp
+
+	// Run under -race: adding must not write to the shared ban
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			first.Get("key")
+		}
+	}()
+	first.Ban(ban)
+	second.Ban(ban)
+	<-done
+
+	if !ban.CreatedAt.Equal(stamp) {
+		t.Errorf("ban time moved from %v to %v", stamp, ban.CreatedAt)
+	}
+	if _, ok := first.Get("key"); ok {
+		t.Error("entry stored before the ban should be banned")
+	}
+
+	older, _ := ParseBan("req.url ~ ^/b")
+	older.CreatedAt = stamp.Add(-time.Second)
+	second.Ban(older)
+	bans := second.Bans()
+	if len(bans) != 2 || bans[0] != older || bans[1] != ban {
+		t.Errorf("bans are not in creation order")
+	}
+}
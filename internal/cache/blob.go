@@ -0,0 +1,73 @@
+package cache
+
+import "crypto/sha256"
+
+// blobHash identifies a body by its content
+type blobHash [sha256.Size]byte
+
+// blob is a body shared by every entry whose response has the same bytes
+type blob struct {
+	data []byte
+	refs int
+}
+
+// blobStore holds response bodies by content hash so byte-identical bodies,
+// such as query-string variants of one page, are kept in memory once. It is
+// guarded by the owning cache's write lock.
+type blobStore struct {
+	blobs map[blobHash]*blob
+
+	// storedBytes counts each distinct body once, logicalBytes counts it
+	// once per referencing entry
+	storedBytes  int64
+	logicalBytes int64
+}
+
+// newBlobStore creates an empty blob store
+func newBlobStore() blobStore {
+	return blobStore{blobs: make(map[blobHash]*blob)}
+}
+
+// acquire adds a reference to body's blob, storing it if it is new, and
+// returns the hash, the shared bytes to keep on the entry and how many new
+// bytes were stored
+func (s *blobStore) acquire(body []byte) (blobHash, []byte, int64) {
+	hash := blobHash(sha256.Sum256(body))
+	s.logicalBytes += int64(len(body))
+
+	if b, exists := s.blobs[hash]; exists {
+		b.refs++
+		return hash, b.data, 0
+	}
+
+	s.blobs[hash] = &blob{data: body, refs: 1}
+	s.storedBytes += int64(len(body))
+	return hash, body, int64(len(body))
+}
+
+// release drops a reference to a blob, freeing it when it was the last one,
+// and returns how many bytes were freed
+func (s *blobStore) release(hash blobHash) int64 {
+	b, exists := s.blobs[hash]
+	if !exists {
+		return 0
+	}
+
+	s.logicalBytes -= int64(len(b.data))
+	b.refs--
+	if b.refs > 0 {
+		return 0
+	}
+
+	delete(s.blobs, hash)
+	s.storedBytes -= int64(len(b.data))
+	return int64(len(b.data))
+}
+
+// ratio returns logical body bytes per stored body byte; 1 means no sharing
+func (s *blobStore) ratio() float64 {
+	if s.storedBytes == 0 {
+		return 1
+	}
+	return float64(s.logicalBytes) / float64(s.storedBytes)
+}
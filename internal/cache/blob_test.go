@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestBlobDedupe checks that identical bodies are stored once and released
+// with the last entry referencing them
+func TestBlobDedupe(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: time.Hour}).(*InMemoryCache)
+	defer c.Close()
+
+	body := bytes.Repeat([]byte("x"), 100)
+	_ = c.Set("a", &Entry{URL: "/page?a", Body: append([]byte(nil), body...)})
+	_ = c.Set("b", &Entry{URL: "/page?b", Body: append([]byte(nil), body...)})
+
+	a, _ := c.Peek("a")
+	b, _ := c.Peek("b")
+	if &a.Body[0] != &b.Body[0] {
+		t.Error("identical bodies should share storage")
+	}
+	stats := c.Stats()
+	if stats.BodyBytes != 200 || stats.StoredBodyBytes != 100 || stats.UniqueBodies != 1 || stats.DedupeRatio != 2 {
+		t.Errorf("body bytes = %d, stored = %d, unique = %d, ratio = %v; want 200, 100, 1, 2",
+			stats.BodyBytes, stats.StoredBodyBytes, stats.UniqueBodies, stats.DedupeRatio)
+	}
+	if want := a.size + b.size + 100; stats.Bytes != want {
+		t.Errorf("bytes = %d, want %d (body counted once)", stats.Bytes, want)
+	}
+
+	_ = c.Delete("a")
+	stats = c.Stats()
+	if stats.StoredBodyBytes != 100 || stats.DedupeRatio != 1 || stats.Bytes != b.size+100 {
+		t.Errorf("after delete: stored = %d, ratio = %v, bytes = %d; want 100, 1, %d",
+			stats.StoredBodyBytes, stats.DedupeRatio, stats.Bytes, b.size+100)
+	}
+	_ = c.Delete("b")
+	if stats := c.Stats(); stats.StoredBodyBytes != 0 || stats.UniqueBodies != 0 || stats.Bytes != 0 {
+		t.Errorf("after deleting all: stored = %d, unique = %d, bytes = %d; want 0", stats.StoredBodyBytes, stats.UniqueBodies, stats.Bytes)
+	}
+}
+
+// TestBlobReleaseOnRetire checks that a body is freed once the version
+// holding it falls out of the history
+func TestBlobReleaseOnRetire(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: time.Hour, Versions: 2}).(*InMemoryCache)
+	defer c.Close()
+
+	_ = c.Set("key", &Entry{URL: "/", Body: []byte("one")})
+	_ = c.Set("key", &Entry{URL: "/", Body: []byte("second")})
+	if stats := c.Stats(); stats.UniqueBodies != 2 || stats.StoredBodyBytes != 9 {
+		t.Fatalf("unique = %d, stored = %d; want 2 and 9 with one version retained", stats.UniqueBodies, stats.StoredBodyBytes)
+	}
+
+	_ = c.Set("key", &Entry{URL: "/", Body: []byte("third!!")})
+	if stats := c.Stats(); stats.UniqueBodies != 2 || stats.StoredBodyBytes != 13 {
+		t.Errorf("unique = %d, stored = %d; want 2 and 13 after the oldest version is dropped", stats.UniqueBodies, stats.StoredBodyBytes)
+	}
+}
+
+// TestBlobReleaseOnShrink checks that shrinking to nothing frees every body
+func TestBlobReleaseOnShrink(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: time.Hour}).(*InMemoryCache)
+	defer c.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = c.Set(key, &Entry{URL: "/" + key, Body: []byte("shared body")})
+	}
+	if evicted := c.Shrink(0); evicted != 3 {
+		t.Errorf("Shrink evicted %d, want 3", evicted)
+	}
+	if stats := c.Stats(); stats.Bytes != 0 || stats.StoredBodyBytes != 0 || stats.BodyBytes != 0 || stats.UniqueBodies != 0 {
+		t.Errorf("bytes = %d, stored = %d, body = %d, unique = %d; want all 0",
+			stats.Bytes, stats.StoredBodyBytes, stats.BodyBytes, stats.UniqueBodies)
+	}
+}
@@ -1,30 +1,118 @@
 package cache
 
 import (
+	"container/list"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Entry represents a cached response with TTL support
 type Entry struct {
+	URL       string        `json:"url"`
+	Host      string        `json:"host"`
+	Route     string        `json:"route"`
+	Method    string        `json:"method"`
 	Body      []byte        `json:"body"`
 	Headers   http.Header   `json:"headers"`
 	Status    int           `json:"status"`
 	CreatedAt time.Time     `json:"created_at"`
 	TTL       time.Duration `json:"ttl"`
+	Pinned    bool          `json:"pinned"`
+	Frozen    bool          `json:"frozen"`
+
+	// Access metadata, updated atomically on every hit
+	hits       int64
+	lastAccess int64
+
+	// Index bookkeeping, guarded by the cache's write lock
+	element *list.Element
+	expiry  *expiryItem
+	size    int64    // bytes accounted outside the shared body blob
+	blob    blobHash // key of the body in the cache's blob store
+	version int64
 }
 
 // IsExpired checks if the cache entry has expired
 func (e *Entry) IsExpired() bool {
-	if e.TTL == 0 {
+	if e.TTL == 0 || e.Pinned || e.Frozen {
 		return false // No expiration
 	}
 	return time.Since(e.CreatedAt) > e.TTL
 }
 
+// TTLRemaining returns how long until the entry expires, or zero if it never does
+func (e *Entry) TTLRemaining() time.Duration {
+	if e.TTL == 0 || e.Pinned || e.Frozen {
+		return 0
+	}
+	if remaining := e.TTL - time.Since(e.CreatedAt); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Size returns the approximate memory footprint of the body and headers
+func (e *Entry) Size() int {
+	size := len(e.Body)
+	for key, values := range e.Headers {
+		size += len(key)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return size
+}
+
+// Hits returns how many times the entry has been served from cache
+func (e *Entry) Hits() int64 {
+	return atomic.LoadInt64(&e.hits)
+}
+
+// LastAccess returns when the entry was last served, or stored if never served
+func (e *Entry) LastAccess() time.Time {
+	if nanos := atomic.LoadInt64(&e.lastAccess); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return e.CreatedAt
+}
+
+// clone copies the entry's data and access metadata without index bookkeeping
+func (e *Entry) clone() *Entry {
+	return &Entry{
+		URL:        e.URL,
+		Host:       e.Host,
+		Route:      e.Route,
+		Method:     e.Method,
+		Body:       e.Body,
+		Headers:    e.Headers,
+		Status:     e.Status,
+		CreatedAt:  e.CreatedAt,
+		TTL:        e.TTL,
+		Pinned:     e.Pinned,
+		Frozen:     e.Frozen,
+		hits:       atomic.LoadInt64(&e.hits),
+		lastAccess: atomic.LoadInt64(&e.lastAccess),
+	}
+}
+
+// touch records a cache hit on the entry
+func (e *Entry) touch() {
+	atomic.AddInt64(&e.hits, 1)
+	atomic.StoreInt64(&e.lastAccess, time.Now().UnixNano())
+}
+
+var (
+	// ErrCacheFull is returned by Set when every entry is pinned and none can be evicted
+	ErrCacheFull = errors.New("cache is full of pinned entries")
+	// ErrNotAdmitted is returned by Set when the admission filter rejects a new entry
+	ErrNotAdmitted = errors.New("entry rejected by admission policy")
+)
+
 // Cache interface defines cache operations
 type Cache interface {
 	Get(key string) (*Entry, bool)
@@ -33,35 +121,107 @@ type Cache interface {
 	Clear() error
 	Size() int
 	Stats() Stats
-	GenerateKey(method, path, query string) string
+	GenerateKey(route, method, host, path, query string) string
+	Ban(ban *Ban)
+	Bans() []*Ban
+	Peek(key string) (*Entry, bool)
+	GetStale(key string) (*Entry, bool)
+	List(filter ListFilter) ([]EntryInfo, int)
+	Pin(key string, pinned bool) error
+	Shrink(targetBytes int64) int
+	Versions(key string) []EntryInfo
+	Promote(key string, version int64, freeze bool) error
+	Freeze(key string, frozen bool) error
+	BumpNamespace(host string) int64
+	Namespace() NamespaceInfo
+	SetTTLExtension(extend func(ttl time.Duration) time.Duration)
 }
 
 // Stats holds cache statistics
 type Stats struct {
-	Hits        int64 `json:"hits"`
-	Misses      int64 `json:"misses"`
-	Size        int   `json:"size"`
-	Evictions   int64 `json:"evictions"`
+	Hits        int64     `json:"hits"`
+	Misses      int64     `json:"misses"`
+	Size        int       `json:"size"`
+	Evictions   int64     `json:"evictions"`
 	LastCleared time.Time `json:"last_cleared"`
+
+	AdmissionRejections int64 `json:"admission_rejections"`
+	Bytes               int64 `json:"bytes"`
+	PressureEvictions   int64 `json:"pressure_evictions"`
+
+	// Bodies are stored once per distinct content; DedupeRatio is
+	// BodyBytes / StoredBodyBytes
+	BodyBytes       int64   `json:"body_bytes"`
+	StoredBodyBytes int64   `json:"stored_body_bytes"`
+	UniqueBodies    int     `json:"unique_bodies"`
+	DedupeRatio     float64 `json:"dedupe_ratio"`
 }
 
 // InMemoryCache implements Cache interface with thread-safe operations and TTL support
 type InMemoryCache struct {
-	data      map[string]*Entry
-	mutex     sync.RWMutex
-	maxSize   int
-	stats     Stats
+	data          map[string]*Entry
+	mutex         sync.RWMutex
+	maxSize       int
+	hits          atomic.Int64
+	misses        atomic.Int64
+	evictions     atomic.Int64
+	rejections    atomic.Int64
+	pressure      atomic.Int64
+	bytes         atomic.Int64
+	lastCleared   time.Time
+	admission     *tinyLFU // nil when every entry is admitted
+	eviction      string
 	cleanupTicker *time.Ticker
 	stopCleanup   chan struct{}
+	bans          banList
+	order         *list.List // keys in insertion order, oldest first
+	expiry        expiryHeap
+	blobs         blobStore
+	history       map[string][]*Entry // replaced versions per key, newest first
+	maxVersions   int
+	nextVersion   int64
+	staleGrace    time.Duration
+	extendTTL     func(ttl time.Duration) time.Duration // nil leaves TTLs as stored
+
+	// Namespace generations folded into every key, guarded by nsMutex
+	nsMutex         sync.RWMutex
+	namespace       string
+	generation      int64
+	hostGenerations map[string]int64
+	lastBumpedAt    *time.Time
+	collect         chan struct{}
+	collected       atomic.Int64
 }
 
 // Config holds cache configuration
 type Config struct {
-	MaxSize       int           `json:"max_size"`
-	DefaultTTL    time.Duration `json:"default_ttl"`
+	MaxSize         int           `json:"max_size"`
+	DefaultTTL      time.Duration `json:"default_ttl"`
 	CleanupInterval time.Duration `json:"cleanup_interval"`
+	Admission       string        `json:"admission"`   // "none" or "tinylfu"
+	Eviction        string        `json:"eviction"`    // "fifo", "lru" or "lfu"
+	Versions        int           `json:"versions"`    // versions kept per key, including the current one
+	Namespace       string        `json:"namespace"`   // folded into every key; changing it invalidates the cache
+	StaleGrace      time.Duration `json:"stale_grace"` // how long expired entries stay available to GetStale
 }
 
+// Eviction policies
+const (
+	EvictionFIFO = "fifo"
+	EvictionLRU  = "lru"
+	EvictionLFU  = "lfu"
+)
+
+// evictionSamples is how many entries LRU and LFU compare when picking a
+// victim, approximating the exact policy without a full scan
+const evictionSamples = 5
+
+// Admission policies
+const (
+	AdmissionNone    = "none"
+	AdmissionTinyLFU = "tinylfu"
+)
+
 // New creates a new cache instance with configuration
 func New(config Config) Cache {
 	if config.MaxSize <= 0 {
@@ -70,12 +230,29 @@ func New(config Config) Cache {
 	if config.CleanupInterval <= 0 {
 		config.CleanupInterval = 5 * time.Minute
 	}
+	if config.Versions <= 0 {
+		config.Versions = 1
+	}
 
 	cache := &InMemoryCache{
 		data:        make(map[string]*Entry),
+		order:       list.New(),
+		blobs:       newBlobStore(),
+		history:     make(map[string][]*Entry),
+		maxVersions: config.Versions,
+		staleGrace:  config.StaleGrace,
 		maxSize:     config.MaxSize,
-		stats:       Stats{LastCleared: time.Now()},
+		lastCleared: time.Now(),
+		eviction:    config.Eviction,
 		stopCleanup: make(chan struct{}),
+
+		namespace:       config.Namespace,
+		hostGenerations: make(map[string]int64),
+		collect:         make(chan struct{}, 1),
+	}
+
+	if config.Admission == AdmissionTinyLFU {
+		cache.admission = newTinyLFU(config.MaxSize)
 	}
 
 	// Start cleanup goroutine for expired entries
@@ -85,29 +262,43 @@ func New(config Config) Cache {
 	return cache
 }
 
-// Get retrieves a cache entry if it exists and is not expired
+// Get retrieves a cache entry if it exists and is not expired. Lookups only
+// take the read lock; stale entries are removed afterwards with a
+// compare-and-delete so a concurrent replacement is never lost.
 func (c *InMemoryCache) Get(key string) (*Entry, bool) {
+	if c.admission != nil {
+		c.admission.record(key)
+	}
+
 	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	
 	entry, exists := c.data[key]
+	expired := exists && c.expired(entry)
+	gone := expired && c.pastGrace(entry)
+	banned := exists && c.bans.banned(entry)
+	c.mutex.RUnlock()
+
 	if !exists {
-		c.stats.Misses++
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	if entry.IsExpired() {
-		c.mutex.RUnlock()
-		c.mutex.Lock()
-		delete(c.data, key)
-		c.mutex.Unlock()
-		c.mutex.RLock()
-		c.stats.Misses++
-		c.stats.Evictions++
+	// Expired entries are left for the next Set to retire as an older
+	// version when versions are kept, or to be served stale within the
+	// grace period; the cleanup sweep removes the rest
+	if banned || (gone && c.maxVersions <= 1) {
+		c.misses.Add(1)
+		if c.compareAndDelete(key, entry) {
+			c.evictions.Add(1)
+		}
+		return nil, false
+	}
+	if expired {
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	c.stats.Hits++
+	c.hits.Add(1)
+	entry.touch()
 	return entry, true
 }
 
@@ -116,13 +307,60 @@ func (c *InMemoryCache) Set(key string, entry *Entry) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// If cache is full, evict oldest entry
-	if len(c.data) >= c.maxSize {
-		c.evictOldest()
+	// If cache is full, evict oldest entry unless this replaces an existing key,
+	// which is kept as an older version. New unpinned entries must also beat
+	// the victim's estimated frequency.
+	if existing, exists := c.data[key]; exists {
+		if existing.Frozen {
+			return ErrFrozen
+		}
+		c.retire(key, existing)
+	} else if len(c.data) >= c.maxSize {
+		victimKey, victim := c.victim()
+		if victim == nil {
+			return ErrCacheFull
+		}
+		if c.admission != nil && !entry.Pinned && !c.admission.admit(key, victimKey) {
+			c.rejections.Add(1)
+			return ErrNotAdmitted
+		}
+		c.remove(victimKey, victim)
+		c.evictions.Add(1)
 	}
 
 	entry.CreatedAt = time.Now()
-	c.data[key] = entry
+	c.store(key, entry)
+	return nil
+}
+
+// Pin exempts an entry from eviction and expiry, or releases it again
+func (c *InMemoryCache) Pin(key string, pinned bool) error {
+	return c.update(key, func(entry *Entry) {
+		entry.Pinned = pinned
+	})
+}
+
+// update applies change to a copy of the current entry for key and swaps it in
+func (c *InMemoryCache) update(key string, change func(*Entry)) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, exists := c.data[key]
+	if !exists {
+		return fmt.Errorf("key not found: %s", key)
+	}
+
+	// Entries handed out by Get are read without the lock, so swap in an
+	// updated copy instead of mutating the shared entry
+	updated := entry.clone()
+	change(updated)
+	updated.element = entry.element
+	updated.size = entry.size
+	updated.blob = entry.blob
+	updated.version = entry.version
+	c.unschedule(entry)
+	c.data[key] = updated
+	c.schedule(key, updated)
 	return nil
 }
 
@@ -130,9 +368,9 @@ func (c *InMemoryCache) Set(key string, entry *Entry) error {
 func (c *InMemoryCache) Delete(key string) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	
-	if _, exists := c.data[key]; exists {
-		delete(c.data, key)
+
+	if entry, exists := c.data[key]; exists {
+		c.remove(key, entry)
 		return nil
 	}
 	return fmt.Errorf("key not found: %s", key)
@@ -142,9 +380,14 @@ func (c *InMemoryCache) Delete(key string) error {
 func (c *InMemoryCache) Clear() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	
+
 	c.data = make(map[string]*Entry)
-	c.stats.LastCleared = time.Now()
+	c.order.Init()
+	c.expiry = nil
+	c.blobs = newBlobStore()
+	c.history = make(map[string][]*Entry)
+	c.bytes.Store(0)
+	c.lastCleared = time.Now()
 	return nil
 }
 
@@ -159,50 +402,215 @@ func (c *InMemoryCache) Size() int {
 func (c *InMemoryCache) Stats() Stats {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	
-	stats := c.stats
-	stats.Size = len(c.data)
-	return stats
+
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Size:        len(c.data),
+		Evictions:   c.evictions.Load(),
+		LastCleared: c.lastCleared,
+
+		AdmissionRejections: c.rejections.Load(),
+		Bytes:               c.bytes.Load(),
+		PressureEvictions:   c.pressure.Load(),
+
+		BodyBytes:       c.blobs.logicalBytes,
+		StoredBodyBytes: c.blobs.storedBytes,
+		UniqueBodies:    len(c.blobs.blobs),
+		DedupeRatio:     c.blobs.ratio(),
+	}
 }
 
-// GenerateKey creates a cache key from method, path, and query parameters
-func (c *InMemoryCache) GenerateKey(method, path, query string) string {
-	content := fmt.Sprintf("%s:%s:%s", method, path, query)
+// GenerateKey creates a cache key from the route name, method, host, path,
+// and query parameters, folding in the namespace and the generations for
+// host. The host is normalized so ports and case don't split entries.
+func (c *InMemoryCache) GenerateKey(route, method, host, path, query string) string {
+	generation, hostGeneration := c.generations(host)
+	content := fmt.Sprintf("%s:%d:%d:%s:%s:%s:%s:%s", c.namespace, generation, hostGeneration, route, method, normalizeHost(host), path, query)
 	hash := sha256.Sum256([]byte(content))
 	return fmt.Sprintf("%x", hash)
 }
 
-// evictOldest removes the oldest entry from the cache
-func (c *InMemoryCache) evictOldest() {
-	var oldestKey string
-	var oldestTime time.Time
-	
+// Ban registers a ban that lazily invalidates matching entries stored before
+// it at lookup time. A ban without a creation time is stamped with the
+// current one; stamp it beforehand to share it between caches.
+func (c *InMemoryCache) Ban(ban *Ban) {
+	c.bans.add(ban)
+}
+
+// Bans returns the currently active bans
+func (c *InMemoryCache) Bans() []*Ban {
+	return c.bans.list()
+}
+
+// store inserts an entry and indexes it, sharing its body with identical
+// bodies already cached. Must be called with the write lock held.
+func (c *InMemoryCache) store(key string, entry *Entry) {
+	hash, body, added := c.blobs.acquire(entry.Body)
+	entry.Body = body
+	entry.blob = hash
+
+	c.nextVersion++
+	entry.version = c.nextVersion
+	entry.size = int64(entry.Size() - len(body))
+	c.bytes.Add(entry.size + added)
+	c.index(key, entry)
+}
+
+// index makes entry the current value for key. Must be called with the
+// write lock held.
+func (c *InMemoryCache) index(key string, entry *Entry) {
+	c.data[key] = entry
+	entry.element = c.order.PushBack(key)
+	c.schedule(key, entry)
+}
+
+// unindex removes entry as the current value for key without releasing
+// its memory. Must be called with the write lock held.
+func (c *InMemoryCache) unindex(key string, entry *Entry) {
+	delete(c.data, key)
+	c.order.Remove(entry.element)
+	c.unschedule(entry)
+}
+
+// release returns an entry's bytes and body reference. Must be called with
+// the write lock held.
+func (c *InMemoryCache) release(entry *Entry) {
+	c.bytes.Add(-entry.size - c.blobs.release(entry.blob))
+}
+
+// remove deletes an entry, its older versions and its index bookkeeping if
+// it is still the current value for key. Must be called with the write
+// lock held.
+func (c *InMemoryCache) remove(key string, entry *Entry) {
+	if c.data[key] != entry {
+		return
+	}
+	c.unindex(key, entry)
+	c.release(entry)
+	c.dropHistory(key)
+}
+
+// Shrink evicts the oldest unpinned entries until the cache holds at most
+// targetBytes, releasing the lock between batches, and returns how many
+// entries were evicted
+func (c *InMemoryCache) Shrink(targetBytes int64) int {
+	evicted := 0
+	for c.bytes.Load() > targetBytes {
+		c.mutex.Lock()
+		batch := 0
+		for batch < expiryBatchSize && c.bytes.Load() > targetBytes {
+			key, entry := c.victim()
+			if entry == nil {
+				break
+			}
+			c.remove(key, entry)
+			batch++
+		}
+		c.mutex.Unlock()
+
+		if batch == 0 {
+			break
+		}
+		evicted += batch
+		c.evictions.Add(int64(batch))
+		c.pressure.Add(int64(batch))
+	}
+	return evicted
+}
+
+// compareAndDelete removes key only if it still maps to entry and reports
+// whether it did
+func (c *InMemoryCache) compareAndDelete(key string, entry *Entry) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.data[key] != entry {
+		return false
+	}
+	c.remove(key, entry)
+	return true
+}
+
+// victim picks the next unpinned entry to evict according to the eviction
+// policy, or nil if every entry is pinned. Must be called with the lock held.
+func (c *InMemoryCache) victim() (string, *Entry) {
+	if c.eviction != EvictionLRU && c.eviction != EvictionLFU {
+		return c.oldest()
+	}
+
+	// Map iteration order is randomized, so the first few unpinned entries
+	// are a random sample
+	var victimKey string
+	var victim *Entry
+	sampled := 0
 	for key, entry := range c.data {
-		if oldestKey == "" || entry.CreatedAt.Before(oldestTime) {
-			oldestKey = key
-			oldestTime = entry.CreatedAt
+		if entry.Pinned {
+			continue
+		}
+		if victim == nil || c.colder(entry, victim) {
+			victimKey, victim = key, entry
+		}
+		if sampled++; sampled == evictionSamples {
+			break
+		}
+	}
+	return victimKey, victim
+}
+
+// colder reports whether a is a better eviction candidate than b
+func (c *InMemoryCache) colder(a, b *Entry) bool {
+	if c.eviction == EvictionLFU {
+		if a.Hits() != b.Hits() {
+			return a.Hits() < b.Hits()
 		}
 	}
-	
-	if oldestKey != "" {
-		delete(c.data, oldestKey)
-		c.stats.Evictions++
+	return a.LastAccess().Before(b.LastAccess())
+}
+
+// oldest returns the oldest unpinned entry, or nil if every entry is pinned.
+// Must be called with the lock held.
+func (c *InMemoryCache) oldest() (string, *Entry) {
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		if entry := c.data[key]; !entry.Pinned {
+			return key, entry
+		}
 	}
+	return "", nil
 }
 
-// cleanupExpired removes expired entries periodically
+// cleanupExpired removes due entries from the expiry index periodically,
+// releasing the lock between small batches
 func (c *InMemoryCache) cleanupExpired() {
 	for {
 		select {
 		case <-c.cleanupTicker.C:
-			c.mutex.Lock()
-			for key, entry := range c.data {
-				if entry.IsExpired() {
-					delete(c.data, key)
-					c.stats.Evictions++
+			for {
+				c.mutex.Lock()
+				removed := c.expireBatch(time.Now(), expiryBatchSize)
+				c.mutex.Unlock()
+				if removed < expiryBatchSize {
+					break
+				}
+			}
+
+			// Bans older than the oldest remaining entry or retained version
+			// can no longer match anything
+			oldest := time.Now()
+			c.mutex.RLock()
+			if front := c.order.Front(); front != nil {
+				oldest = c.data[front.Value.(string)].CreatedAt
+			}
+			for _, versions := range c.history {
+				if n := len(versions); n > 0 && versions[n-1].CreatedAt.Before(oldest) {
+					oldest = versions[n-1].CreatedAt
 				}
 			}
-			c.mutex.Unlock()
+			c.mutex.RUnlock()
+			c.bans.prune(oldest)
+		case <-c.collect:
+			c.collectGarbage()
 		case <-c.stopCleanup:
 			c.cleanupTicker.Stop()
 			return
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestInMemoryCacheConcurrentAccess hammers the cache from many goroutines,
+// including entries that expire mid-test, and is meant to run under -race.
+func TestInMemoryCacheConcurrentAccess(t *testing.T) {
+	c := New(Config{MaxSize: 64, CleanupInterval: time.Millisecond}).(*InMemoryCache)
+	defer c.Close()
+
+	const (
+		workers    = 16
+		iterations = 2000
+		keys       = 100
+	)
+
+	ban, err := ParseBan("req.url ~ ^/banned")
+	if err != nil {
+		t.Fatalf("ParseBan: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var getCount atomic.Int64
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				key := fmt.Sprintf("key-%d", (worker*iterations+i)%keys)
+
+				switch i % 10 {
+				case 0, 1, 2:
+					ttl := time.Minute
+					if i%3 == 0 {
+						ttl = time.Microsecond
+					}
+					_ = c.Set(key, &Entry{
+						URL:     "/" + key,
+						Method:  http.MethodGet,
+						Body:    []byte(key),
+						Headers: http.Header{"Content-Type": []string{"text/plain"}},
+						Status:  http.StatusOK,
+						TTL:     ttl,
+					})
+				case 3:
+					_ = c.Delete(key)
+				case 4:
+					_ = c.Pin(key, i%20 == 4)
+				case 5:
+					c.Stats()
+					c.List(ListFilter{Limit: 10})
+				case 6:
+					if i%500 == 6 {
+						c.Ban(ban)
+					}
+				default:
+					if entry, ok := c.Get(key); ok {
+						_ = entry.Hits()
+						_ = entry.Info(key)
+					}
+					getCount.Add(1)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != getCount.Load() {
+		t.Fatalf("hits (%d) + misses (%d) = %d, want %d Get calls", stats.Hits, stats.Misses, stats.Hits+stats.Misses, getCount.Load())
+	}
+	if stats.Size > 64 {
+		t.Fatalf("size %d exceeds max size 64", stats.Size)
+	}
+}
+
+// TestGenerateKeyHost checks that hosts get separate keys unless they differ
+// only by case or port
+func TestGenerateKeyHost(t *testing.T) {
+	c := New(Config{MaxSize: 10}).(*InMemoryCache)
+	defer c.Close()
+
+	base := c.GenerateKey("default", http.MethodGet, "a.example.com", "/page", "q=1")
+	tests := []struct {
+		host string
+		same bool
+	}{
+		{"a.example.com", true},
+		{"A.Example.COM", true},
+		{"a.example.com:8080", true},
+		{"b.example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		key := c.GenerateKey("default", http.MethodGet, tt.host, "/page", "q=1")
+		if (key == base) != tt.same {
+			t.Errorf("host %q: same key = %v, want %v", tt.host, key == base, tt.same)
+		}
+	}
+}
+
+// TestTTLExtension checks that an extension keeps entries fresh past their
+// stored TTL, including through the cleanup sweep, and that they expire
+// again once it stops stretching them
+func TestTTLExtension(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: 5 * time.Millisecond}).(*InMemoryCache)
+	defer c.Close()
+
+	var factor atomic.Int64
+	factor.Store(100)
+	c.SetTTLExtension(func(ttl time.Duration) time.Duration {
+		return ttl * time.Duration(factor.Load())
+	})
+
+	if err := c.Set("key", &Entry{URL: "/a", TTL: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("key"); !ok {
+		t.Fatal("entry should still be fresh while its TTL is extended")
+	}
+
+	factor.Store(1)
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("entry should expire once its TTL is no longer extended")
+	}
+}
+
+// TestExpireBatch checks that due entries are removed at most limit at a
+// time and entries not yet due are left alone
+func TestExpireBatch(t *testing.T) {
+	c := New(Config{MaxSize: 1000, CleanupInterval: time.Hour}).(*InMemoryCache)
+	defer c.Close()
+
+	const due = 2*expiryBatchSize + 44
+	for i := 0; i < due; i++ {
+		_ = c.Set(fmt.Sprintf("due-%d", i), &Entry{URL: "/due", TTL: time.Millisecond})
+	}
+	_ = c.Set("fresh", &Entry{URL: "/fresh", TTL: time.Hour})
+	_ = c.Set("forever", &Entry{URL: "/forever"})
+
+	now := time.Now().Add(time.Second)
+	for _, want := range []int{expiryBatchSize, expiryBatchSize, 44, 0} {
+		c.mutex.Lock()
+		handled := c.expireBatch(now, expiryBatchSize)
+		c.mutex.Unlock()
+		if handled != want {
+			t.Fatalf("expireBatch handled %d, want %d", handled, want)
+		}
+	}
+
+	if size := c.Size(); size != 2 {
+		t.Errorf("size = %d, want 2", size)
+	}
+	if evictions := c.Stats().Evictions; evictions != due {
+		t.Errorf("evictions = %d, want %d", evictions, due)
+	}
+	if len(c.expiry) != 1 || c.expiry[0].key != "fresh" {
+		t.Errorf("expiry index holds %d items, want only fresh", len(c.expiry))
+	}
+}
+
+// TestExpireBatchKeepsExtendedAndPinned checks that an entry whose TTL is
+// extended is rescheduled for its extended expiry, and a pinned entry stays
+// out of the index until it is unpinned
+func TestExpireBatchKeepsExtendedAndPinned(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: time.Hour}).(*InMemoryCache)
+	defer c.Close()
+
+	c.SetTTLExtension(func(ttl time.Duration) time.Duration {
+		if ttl == 10*time.Millisecond {
+			return time.Hour
+		}
+		return ttl
+	})
+	_ = c.Set("extended", &Entry{URL: "/extended", TTL: 10 * time.Millisecond})
+	_ = c.Set("pinned", &Entry{URL: "/pinned", TTL: time.Millisecond})
+	_ = c.Set("plain", &Entry{URL: "/plain", TTL: time.Millisecond})
+	if err := c.Pin("pinned", true); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	now := time.Now().Add(time.Second)
+	c.mutex.Lock()
+	c.expireBatch(now, expiryBatchSize)
+	c.mutex.Unlock()
+
+	if _, ok := c.Peek("plain"); ok {
+		t.Error("plain entry should have expired")
+	}
+	for _, key := range []string{"extended", "pinned"} {
+		if _, ok := c.Peek(key); !ok {
+			t.Errorf("%s entry was dropped", key)
+		}
+	}
+	if len(c.expiry) != 1 || c.expiry[0].key != "extended" {
+		t.Fatalf("expiry index holds %d items, want only extended", len(c.expiry))
+	}
+	if entry, _ := c.Peek("extended"); !c.expiry[0].expiresAt.Equal(entry.CreatedAt.Add(time.Hour)) {
+		t.Errorf("extended entry rescheduled for %v, want its extended expiry", c.expiry[0].expiresAt)
+	}
+
+	// Unpinned, it is due again and goes with the next batch
+	if err := c.Pin("pinned", false); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	c.mutex.Lock()
+	c.expireBatch(now, expiryBatchSize)
+	c.mutex.Unlock()
+	if _, ok := c.Peek("pinned"); ok {
+		t.Error("unpinned entry should have expired")
+	}
+}
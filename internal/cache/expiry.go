@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryBatchSize bounds how many entries are expired per write-lock hold so
+// cleanup never stalls requests for long on large caches
+const expiryBatchSize = 128
+
+// expiryItem tracks when a single entry is due to expire
+type expiryItem struct {
+	key       string
+	expiresAt time.Time
+	index     int
+}
+
+// expiryHeap is a min-heap of entries ordered by expiry time
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// schedule adds an entry to the expiry index if it can expire.
+// Must be called with the write lock held.
+func (c *InMemoryCache) schedule(key string, entry *Entry) {
+	if entry.TTL == 0 || entry.Pinned || entry.Frozen || entry.expiry != nil {
+		return
+	}
+	entry.expiry = &expiryItem{key: key, expiresAt: entry.CreatedAt.Add(entry.TTL + c.staleGrace)}
+	heap.Push(&c.expiry, entry.expiry)
+}
+
+// SetTTLExtension sets a function that stretches stored TTLs whenever
+// freshness is checked, such as while the origin is struggling. Entries
+// keep their own TTL, so they expire normally again once it stops.
+func (c *InMemoryCache) SetTTLExtension(extend func(ttl time.Duration) time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.extendTTL = extend
+}
+
+// ttl returns the TTL currently in effect for an entry.
+// Must be called with the lock held.
+func (c *InMemoryCache) ttl(entry *Entry) time.Duration {
+	if c.extendTTL == nil || entry.TTL == 0 {
+		return entry.TTL
+	}
+	return c.extendTTL(entry.TTL)
+}
+
+// expired is IsExpired with the TTL currently in effect.
+// Must be called with the lock held.
+func (c *InMemoryCache) expired(entry *Entry) bool {
+	if entry.TTL == 0 || entry.Pinned || entry.Frozen {
+		return false
+	}
+	return time.Since(entry.CreatedAt) > c.ttl(entry)
+}
+
+// pastGrace reports whether an expired entry can no longer be served stale.
+// Must be called with the lock held.
+func (c *InMemoryCache) pastGrace(entry *Entry) bool {
+	return time.Since(entry.CreatedAt) > c.ttl(entry)+c.staleGrace
+}
+
+// unschedule removes an entry from the expiry index.
+// Must be called with the write lock held.
+func (c *InMemoryCache) unschedule(entry *Entry) {
+	if entry.expiry == nil {
+		return
+	}
+	heap.Remove(&c.expiry, entry.expiry.index)
+	entry.expiry = nil
+}
+
+// expireBatch removes up to limit entries whose expiry time has passed and
+// returns how many were handled. Entries whose TTL is currently extended
+// are rescheduled instead. Must be called with the write lock held.
+func (c *InMemoryCache) expireBatch(now time.Time, limit int) int {
+	handled := 0
+	for handled < limit && len(c.expiry) > 0 && !c.expiry[0].expiresAt.After(now) {
+		item := heap.Pop(&c.expiry).(*expiryItem)
+		handled++
+		entry, exists := c.data[item.key]
+		if !exists {
+			continue
+		}
+		if expiresAt := entry.CreatedAt.Add(c.ttl(entry) + c.staleGrace); expiresAt.After(now) {
+			item.expiresAt = expiresAt
+			heap.Push(&c.expiry, item)
+			continue
+		}
+		entry.expiry = nil
+		c.remove(item.key, entry)
+		c.evictions.Add(1)
+	}
+	return handled
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntryInfo is a read-only snapshot of an entry's metadata for inspection
+type EntryInfo struct {
+	Key          string        `json:"key"`
+	URL          string        `json:"url"`
+	Host         string        `json:"host,omitempty"`
+	Route        string        `json:"route,omitempty"`
+	Method       string        `json:"method"`
+	Status       int           `json:"status"`
+	ContentType  string        `json:"content_type"`
+	Size         int           `json:"size"`
+	CreatedAt    time.Time     `json:"created_at"`
+	TTL          time.Duration `json:"ttl"`
+	TTLRemaining time.Duration `json:"ttl_remaining"`
+	Hits         int64         `json:"hits"`
+	LastAccess   time.Time     `json:"last_access"`
+	Pinned       bool          `json:"pinned"`
+	Frozen       bool          `json:"frozen"`
+	Version      int64         `json:"version"`
+	Validator    string        `json:"validator"`
+	Zone         string        `json:"zone,omitempty"`
+}
+
+// ListFilter selects and paginates entries for inspection. Zero values
+// disable the corresponding filter.
+type ListFilter struct {
+	PathPrefix  string        `json:"path_prefix"`
+	Status      string        `json:"status"`       // exact code ("404") or class ("4xx")
+	ContentType string        `json:"content_type"` // media type prefix, e.g. "image/"
+	MinAge      time.Duration `json:"min_age"`
+	MaxAge      time.Duration `json:"max_age"`
+	MinSize     int           `json:"min_size"`
+	MaxSize     int           `json:"max_size"`
+	Offset      int           `json:"offset"`
+	Limit       int           `json:"limit"`
+}
+
+// Info returns a metadata snapshot of the entry stored under key
+func (e *Entry) Info(key string) EntryInfo {
+	return EntryInfo{
+		Key:          key,
+		URL:          e.URL,
+		Host:         e.Host,
+		Route:        e.Route,
+		Method:       e.Method,
+		Status:       e.Status,
+		ContentType:  e.Headers.Get("Content-Type"),
+		Size:         e.Size(),
+		CreatedAt:    e.CreatedAt,
+		TTL:          e.TTL,
+		TTLRemaining: e.TTLRemaining(),
+		Hits:         e.Hits(),
+		LastAccess:   e.LastAccess(),
+		Pinned:       e.Pinned,
+		Frozen:       e.Frozen,
+		Version:      e.version,
+		Validator:    e.Validator(),
+	}
+}
+
+// Matches reports whether the entry passes every filter criterion
+func (f ListFilter) Matches(info EntryInfo) bool {
+	if f.PathPrefix != "" && !strings.HasPrefix(info.URL, f.PathPrefix) {
+		return false
+	}
+
+	if f.Status != "" {
+		code := strconv.Itoa(info.Status)
+		if strings.HasSuffix(strings.ToLower(f.Status), "xx") {
+			if code[:1] != f.Status[:1] {
+				return false
+			}
+		} else if code != f.Status {
+			return false
+		}
+	}
+
+	if f.ContentType != "" && !strings.HasPrefix(strings.ToLower(info.ContentType), strings.ToLower(f.ContentType)) {
+		return false
+	}
+
+	age := time.Since(info.CreatedAt)
+	if f.MinAge > 0 && age < f.MinAge {
+		return false
+	}
+	if f.MaxAge > 0 && age > f.MaxAge {
+		return false
+	}
+
+	if f.MinSize > 0 && info.Size < f.MinSize {
+		return false
+	}
+	if f.MaxSize > 0 && info.Size > f.MaxSize {
+		return false
+	}
+
+	return true
+}
+
+// Peek returns an entry without updating hit statistics or access metadata
+func (c *InMemoryCache) Peek(key string) (*Entry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, exists := c.data[key]
+	if !exists || c.expired(entry) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// GetStale returns an entry even if it has expired, as long as it is still
+// held within the stale grace period and not banned. It is meant for serving
+// something when the origin cannot be reached.
+func (c *InMemoryCache) GetStale(key string) (*Entry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, exists := c.data[key]
+	if !exists || c.bans.banned(entry) || (c.expired(entry) && c.pastGrace(entry)) {
+		return nil, false
+	}
+	entry.touch()
+	return entry, true
+}
+
+// List returns one page of live entries matching the filter, ordered by URL,
+// together with the total number of matches
+func (c *InMemoryCache) List(filter ListFilter) ([]EntryInfo, int) {
+	c.mutex.RLock()
+	matches := make([]EntryInfo, 0, len(c.data))
+	for key, entry := range c.data {
+		if c.expired(entry) {
+			continue
+		}
+		if info := entry.Info(key); filter.Matches(info) {
+			matches = append(matches, info)
+		}
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].URL != matches[j].URL {
+			return matches[i].URL < matches[j].URL
+		}
+		return matches[i].Key < matches[j].Key
+	})
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return []EntryInfo{}, total
+	}
+	matches = matches[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matches) {
+		matches = matches[:filter.Limit]
+	}
+	return matches, total
+}
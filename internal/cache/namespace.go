@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NamespaceInfo describes the generation counters folded into cache keys
+type NamespaceInfo struct {
+	Namespace       string           `json:"namespace"`
+	Generation      int64            `json:"generation"`
+	HostGenerations map[string]int64 `json:"host_generations"`
+	Collected       int64            `json:"collected"`
+	LastBumpedAt    *time.Time       `json:"last_bumped_at,omitempty"`
+}
+
+// BumpNamespace increments the generation for host, or the cache-wide
+// generation when host is empty, so every existing key for it stops
+// matching. The orphaned entries are collected in the background.
+func (c *InMemoryCache) BumpNamespace(host string) int64 {
+	c.nsMutex.Lock()
+	var generation int64
+	if host == "" {
+		c.generation++
+		generation = c.generation
+	} else {
+		host = normalizeHost(host)
+		c.hostGenerations[host]++
+		generation = c.hostGenerations[host]
+	}
+	now := time.Now()
+	c.lastBumpedAt = &now
+	c.nsMutex.Unlock()
+
+	select {
+	case c.collect <- struct{}{}:
+	default: // a collection is already pending
+	}
+	return generation
+}
+
+// Namespace returns the cache's namespace and generation counters
+func (c *InMemoryCache) Namespace() NamespaceInfo {
+	c.nsMutex.RLock()
+	defer c.nsMutex.RUnlock()
+
+	hosts := make(map[string]int64, len(c.hostGenerations))
+	for host, generation := range c.hostGenerations {
+		hosts[host] = generation
+	}
+	return NamespaceInfo{
+		Namespace:       c.namespace,
+		Generation:      c.generation,
+		HostGenerations: hosts,
+		Collected:       c.collected.Load(),
+		LastBumpedAt:    c.lastBumpedAt,
+	}
+}
+
+// generations returns the cache-wide and per-host generation for host
+func (c *InMemoryCache) generations(host string) (int64, int64) {
+	c.nsMutex.RLock()
+	defer c.nsMutex.RUnlock()
+	return c.generation, c.hostGenerations[normalizeHost(host)]
+}
+
+// keyFor regenerates the key an entry would be stored under today
+func (c *InMemoryCache) keyFor(entry *Entry) string {
+	target, err := url.ParseRequestURI(entry.URL)
+	if err != nil {
+		return ""
+	}
+	return c.GenerateKey(entry.Route, entry.Method, entry.Host, target.Path, target.RawQuery)
+}
+
+// collectGarbage removes entries whose key no longer matches the current
+// namespace generations, taking the write lock one batch at a time
+func (c *InMemoryCache) collectGarbage() {
+	c.mutex.RLock()
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+	c.mutex.RUnlock()
+
+	for start := 0; start < len(keys); start += expiryBatchSize {
+		end := start + expiryBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		c.mutex.Lock()
+		for _, key := range keys[start:end] {
+			if entry, exists := c.data[key]; exists && c.keyFor(entry) != key {
+				c.remove(key, entry)
+				c.collected.Add(1)
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// normalizeHost lowercases a host and strips any port
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestBumpNamespace checks that host and global bumps change the keys for
+// the affected hosts only, and that collectGarbage reclaims the orphans
+func TestBumpNamespace(t *testing.T) {
+	c := New(Config{MaxSize: 10, CleanupInterval: time.Hour}).(*InMemoryCache)
+	defer c.Close()
+
+	keys := make(map[string]string)
+	for _, host := range []string{"a.test", "b.test"} {
+		keys[host] = c.GenerateKey("default", http.MethodGet, host, "/page", "")
+		_ = c.Set(keys[host], &Entry{URL: "/page", Method: http.MethodGet, Host: host, Route: "default"})
+	}
+
+	if generation := c.BumpNamespace("A.test:8080"); generation != 1 {
+		t.Errorf("host generation = %d, want 1", generation)
+	}
+	if c.GenerateKey("default", http.MethodGet, "a.test", "/page", "") == keys["a.test"] {
+		t.Error("host bump should change the host's keys")
+	}
+	if c.GenerateKey("default", http.MethodGet, "b.test", "/page", "") != keys["b.test"] {
+		t.Error("host bump should leave other hosts' keys alone")
+	}
+
+	c.collectGarbage()
+	if _, ok := c.Peek(keys["a.test"]); ok {
+		t.Error("bumped host's entry should be collected")
+	}
+	if _, ok := c.Peek(keys["b.test"]); !ok {
+		t.Error("other host's entry should survive collection")
+	}
+
+	c.BumpNamespace("")
+	if _, ok := c.Get(c.GenerateKey("default", http.MethodGet, "b.test", "/page", "")); ok {
+		t.Error("global bump should make every existing key unreachable")
+	}
+	c.collectGarbage()
+
+	info := c.Namespace()
+	if c.Size() != 0 || info.Collected != 2 {
+		t.Errorf("size = %d, collected = %d; want 0 and 2", c.Size(), info.Collected)
+	}
+	if info.Generation != 1 || info.HostGenerations["a.test"] != 1 || info.LastBumpedAt == nil {
+		t.Errorf("namespace info = %+v, want generation 1 and a.test at 1", info)
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// StatusPolicy decides whether a response is cached, and for how long,
+// based on its status code. A zero TTL disables caching for every class
+// except success, where zero keeps the entry until evicted. Success covers
+// only complete responses: 206 Partial Content is never cached, since the
+// cache key does not include Range.
+type StatusPolicy struct {
+	SuccessTTL           time.Duration `json:"success_ttl"`
+	NegativeTTL          time.Duration `json:"negative_ttl"`
+	CacheServerErrors    bool          `json:"cache_server_errors"`
+	ServerErrorTTL       time.Duration `json:"server_error_ttl"`
+	PermanentRedirectTTL time.Duration `json:"permanent_redirect_ttl"`
+	TemporaryRedirectTTL time.Duration `json:"temporary_redirect_ttl"`
+}
+
+// TTLFor returns the TTL for a response status and whether it should be cached
+func (p StatusPolicy) TTLFor(status int) (time.Duration, bool) {
+	switch {
+	case status == http.StatusOK || status == http.StatusNonAuthoritativeInfo || status == http.StatusNoContent:
+		return p.SuccessTTL, true
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return p.NegativeTTL, p.NegativeTTL > 0
+	case status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect:
+		return p.PermanentRedirectTTL, p.PermanentRedirectTTL > 0
+	case status == http.StatusFound || status == http.StatusTemporaryRedirect:
+		return p.TemporaryRedirectTTL, p.TemporaryRedirectTTL > 0
+	case status >= 500:
+		return p.ServerErrorTTL, p.CacheServerErrors && p.ServerErrorTTL > 0
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestStatusPolicyTTLFor covers each status class, with caching of the
+// optional classes both on and off
+func TestStatusPolicyTTLFor(t *testing.T) {
+	enabled := StatusPolicy{
+		SuccessTTL:           time.Hour,
+		NegativeTTL:          time.Minute,
+		CacheServerErrors:    true,
+		ServerErrorTTL:       5 * time.Second,
+		PermanentRedirectTTL: 24 * time.Hour,
+		TemporaryRedirectTTL: 10 * time.Minute,
+	}
+	disabled := StatusPolicy{SuccessTTL: time.Hour}
+	serverErrorsOff := enabled
+	serverErrorsOff.CacheServerErrors = false
+
+	tests := []struct {
+		name      string
+		policy    StatusPolicy
+		status    int
+		wantTTL   time.Duration
+		wantCache bool
+	}{
+		{"ok", enabled, http.StatusOK, time.Hour, true},
+		{"non-authoritative", enabled, http.StatusNonAuthoritativeInfo, time.Hour, true},
+		{"no content", enabled, http.StatusNoContent, time.Hour, true},
+		{"partial content is never cached", enabled, http.StatusPartialContent, 0, false},
+		{"created is never cached", enabled, http.StatusCreated, 0, false},
+		{"success without ttl never expires", StatusPolicy{}, http.StatusOK, 0, true},
+		{"not found", enabled, http.StatusNotFound, time.Minute, true},
+		{"gone", enabled, http.StatusGone, time.Minute, true},
+		{"moved permanently", enabled, http.StatusMovedPermanently, 24 * time.Hour, true},
+		{"permanent redirect", enabled, http.StatusPermanentRedirect, 24 * time.Hour, true},
+		{"found", enabled, http.StatusFound, 10 * time.Minute, true},
+		{"temporary redirect", enabled, http.StatusTemporaryRedirect, 10 * time.Minute, true},
+		{"server error", enabled, http.StatusBadGateway, 5 * time.Second, true},
+		{"server errors not enabled", serverErrorsOff, http.StatusInternalServerError, 5 * time.Second, false},
+		{"negative disabled", disabled, http.StatusNotFound, 0, false},
+		{"redirects disabled", disabled, http.StatusMovedPermanently, 0, false},
+		{"server error disabled", disabled, http.StatusServiceUnavailable, 0, false},
+		{"see other is never cached", enabled, http.StatusSeeOther, 0, false},
+		{"client error is never cached", enabled, http.StatusBadRequest, 0, false},
+		{"informational is never cached", enabled, http.StatusContinue, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, cacheable := tt.policy.TTLFor(tt.status)
+			if ttl != tt.wantTTL || cacheable != tt.wantCache {
+				t.Errorf("TTLFor(%d) = %v, %v; want %v, %v", tt.status, ttl, cacheable, tt.wantTTL, tt.wantCache)
+			}
+		})
+	}
+}
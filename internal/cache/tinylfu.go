@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// sketchDepth is the number of count-min sketch rows
+	sketchDepth = 4
+	// sketchMaxCount is where 4-bit style counters saturate
+	sketchMaxCount = 15
+	// sampleFactor sets the aging window as a multiple of cache capacity
+	sampleFactor = 10
+)
+
+// tinyLFU is a W-TinyLFU style admission filter. A doorkeeper bloom filter
+// absorbs first accesses so one-hit wonders never reach the count-min
+// sketch, and all counts are halved periodically so the filter follows
+// shifts in popularity.
+type tinyLFU struct {
+	mutex      sync.Mutex
+	sketch     [sketchDepth][]uint8
+	sketchMask uint64
+	door       []uint64
+	doorMask   uint64
+	samples    int
+	resetAt    int
+}
+
+// newTinyLFU sizes the filter for a cache holding capacity entries
+func newTinyLFU(capacity int) *tinyLFU {
+	// The doorkeeper sees every distinct key of an aging window, so it gets
+	// roughly eight bits per sample to keep false positives low
+	width := nextPowerOfTwo(capacity)
+	doorBits := nextPowerOfTwo(capacity * sampleFactor * 8)
+
+	t := &tinyLFU{
+		sketchMask: uint64(width - 1),
+		door:       make([]uint64, doorBits/64),
+		doorMask:   uint64(doorBits - 1),
+		resetAt:    capacity * sampleFactor,
+	}
+	for i := range t.sketch {
+		t.sketch[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// record counts one access to key
+func (t *tinyLFU) record(key string) {
+	h1, h2 := hashKey(key)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.doorkeep(h1, h2) {
+		for i := 0; i < sketchDepth; i++ {
+			counter := &t.sketch[i][(h1+uint64(i)*h2)&t.sketchMask]
+			if *counter < sketchMaxCount {
+				*counter++
+			}
+		}
+	}
+
+	t.samples++
+	if t.samples >= t.resetAt {
+		t.reset()
+	}
+}
+
+// admit reports whether candidate is estimated to be used more often than
+// the victim it would replace
+func (t *tinyLFU) admit(candidate, victim string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// estimate returns the approximate access count for key. Must be called
+// with the mutex held.
+func (t *tinyLFU) estimate(key string) int {
+	h1, h2 := hashKey(key)
+
+	lowest := sketchMaxCount
+	for i := 0; i < sketchDepth; i++ {
+		if count := int(t.sketch[i][(h1+uint64(i)*h2)&t.sketchMask]); count < lowest {
+			lowest = count
+		}
+	}
+
+	if t.inDoor(h1, h2) {
+		lowest++
+	}
+	return lowest
+}
+
+// doorkeep adds the key to the doorkeeper and reports whether it was
+// already present. Must be called with the mutex held.
+func (t *tinyLFU) doorkeep(h1, h2 uint64) bool {
+	present := true
+	for i := uint64(0); i < 2; i++ {
+		bit := (h1 + i*h2) & t.doorMask
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if t.door[word]&mask == 0 {
+			present = false
+			t.door[word] |= mask
+		}
+	}
+	return present
+}
+
+// inDoor reports whether the key is in the doorkeeper. Must be called with
+// the mutex held.
+func (t *tinyLFU) inDoor(h1, h2 uint64) bool {
+	for i := uint64(0); i < 2; i++ {
+		bit := (h1 + i*h2) & t.doorMask
+		if t.door[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reset halves every counter and clears the doorkeeper. Must be called
+// with the mutex held.
+func (t *tinyLFU) reset() {
+	for i := range t.sketch {
+		for j := range t.sketch[i] {
+			t.sketch[i][j] >>= 1
+		}
+	}
+	for i := range t.door {
+		t.door[i] = 0
+	}
+	t.samples = 0
+}
+
+// hashKey returns two independent hashes of key for double hashing
+func hashKey(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, (sum >> 32) | 1
+}
+
+// nextPowerOfTwo returns the smallest power of two >= n, with a floor of 1024
+func nextPowerOfTwo(n int) int {
+	p := 1024
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestTinyLFUAdmit checks that a key seen once loses to a hot one
+func TestTinyLFUAdmit(t *testing.T) {
+	filter := newTinyLFU(100)
+	for i := 0; i < 10; i++ {
+		filter.record("hot")
+	}
+	filter.record("once")
+
+	if filter.admit("once", "hot") {
+		t.Error("one-hit candidate should not replace a hot victim")
+	}
+	if !filter.admit("hot", "once") {
+		t.Error("hot candidate should replace a one-hit victim")
+	}
+	if filter.admit("never", "once") {
+		t.Error("unseen candidate should not replace a victim seen once")
+	}
+}
+
+// TestTinyLFUReset checks that counts are halved and the doorkeeper cleared
+// once an aging window of samples has been recorded
+func TestTinyLFUReset(t *testing.T) {
+	filter := newTinyLFU(10)
+	for i := 0; i < 8; i++ {
+		filter.record("hot")
+	}
+	// The first access only reaches the doorkeeper
+	if got := filter.estimate("hot"); got != 8 {
+		t.Fatalf("estimate before reset = %d, want 8", got)
+	}
+
+	for i := filter.samples; i < filter.resetAt; i++ {
+		filter.record(fmt.Sprintf("other-%d", i))
+	}
+	if filter.samples != 0 {
+		t.Errorf("samples after reset = %d, want 0", filter.samples)
+	}
+	if got := filter.estimate("hot"); got != 3 {
+		t.Errorf("estimate after reset = %d, want 3 (7 halved, doorkeeper cleared)", got)
+	}
+}
+
+// TestTinyLFUAdmission checks that a full cache rejects a one-hit key in
+// favour of its hot entries, counts the rejection, and admits a hot key
+func TestTinyLFUAdmission(t *testing.T) {
+	c := New(Config{MaxSize: 2, CleanupInterval: time.Hour, Admission: AdmissionTinyLFU}).(*InMemoryCache)
+	defer c.Close()
+
+	for _, key := range []string{"a", "b"} {
+		_ = c.Set(key, &Entry{URL: "/" + key})
+		for i := 0; i < 5; i++ {
+			c.Get(key)
+		}
+	}
+
+	c.Get("once")
+	if err := c.Set("once", &Entry{URL: "/once"}); err != ErrNotAdmitted {
+		t.Fatalf("Set(once) = %v, want ErrNotAdmitted", err)
+	}
+	if stats := c.Stats(); stats.AdmissionRejections != 1 || stats.Size != 2 {
+		t.Errorf("rejections = %d, size = %d; want 1 and 2", stats.AdmissionRejections, stats.Size)
+	}
+
+	for i := 0; i < 10; i++ {
+		c.Get("popular")
+	}
+	if err := c.Set("popular", &Entry{URL: "/popular"}); err != nil {
+		t.Fatalf("Set(popular) = %v, want it admitted", err)
+	}
+	if _, ok := c.Peek("popular"); !ok {
+		t.Error("admitted entry should be cached")
+	}
+}
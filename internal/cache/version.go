@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrFrozen is returned by Set when the current entry for a key is frozen
+	ErrFrozen = errors.New("entry is frozen")
+	// ErrVersionNotFound is returned by Promote for an unknown version
+	ErrVersionNotFound = errors.New("version not found")
+	// ErrVersionBanned is returned by Promote for a version an active ban covers
+	ErrVersionBanned = errors.New("version is banned")
+)
+
+// Version returns the entry's version number, unique within its cache
+func (e *Entry) Version() int64 {
+	return e.version
+}
+
+// Validator returns the entry's ETag, its Last-Modified date, or a hash of
+// its body when the origin sent neither
+func (e *Entry) Validator() string {
+	if etag := e.Headers.Get("ETag"); etag != "" {
+		return etag
+	}
+	if modified := e.Headers.Get("Last-Modified"); modified != "" {
+		return modified
+	}
+	return "sha256:" + hex.EncodeToString(e.blob[:])
+}
+
+// Versions returns the current entry for key followed by its retained
+// older versions, newest first
+func (c *InMemoryCache) Versions(key string) []EntryInfo {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var versions []EntryInfo
+	if entry, exists := c.data[key]; exists {
+		versions = append(versions, entry.Info(key))
+	}
+	for _, entry := range c.history[key] {
+		info := entry.Info(key)
+		info.TTLRemaining = 0
+		versions = append(versions, info)
+	}
+	return versions
+}
+
+// Promote makes a retained version current again, restarting its TTL. The
+// replaced entry is kept as a version so the rollback can itself be undone.
+// Versions stored before a ban that matches them can't be promoted, since
+// restarting the TTL would also move them past the ban.
+func (c *InMemoryCache) Promote(key string, version int64, freeze bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	history := c.history[key]
+	index := -1
+	for i, entry := range history {
+		if entry.version == version {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %d for key %s", ErrVersionNotFound, version, key)
+	}
+
+	old := history[index]
+	if c.bans.banned(old) {
+		return fmt.Errorf("%w: %d for key %s", ErrVersionBanned, version, key)
+	}
+	c.history[key] = append(history[:index:index], history[index+1:]...)
+
+	// The retained entry may still be held by readers from before it was
+	// replaced, so promote a copy that takes over its blob reference
+	promoted := old.clone()
+	promoted.version = old.version
+	promoted.blob = old.blob
+	promoted.size = old.size
+	promoted.Frozen = freeze
+	promoted.CreatedAt = time.Now()
+
+	if current, exists := c.data[key]; exists {
+		c.retire(key, current)
+	}
+	c.index(key, promoted)
+	return nil
+}
+
+// Freeze stops Set from replacing the current entry for key, or allows it again
+func (c *InMemoryCache) Freeze(key string, frozen bool) error {
+	return c.update(key, func(entry *Entry) {
+		entry.Frozen = frozen
+	})
+}
+
+// retire unindexes the current entry for key and keeps it as the newest
+// version, dropping the oldest beyond the configured limit. Must be called
+// with the write lock held.
+func (c *InMemoryCache) retire(key string, entry *Entry) {
+	if c.maxVersions <= 1 {
+		c.remove(key, entry)
+		return
+	}
+
+	c.unindex(key, entry)
+	history := append([]*Entry{entry}, c.history[key]...)
+	for len(history) >= c.maxVersions {
+		c.release(history[len(history)-1])
+		history = history[:len(history)-1]
+	}
+	c.history[key] = history
+}
+
+// dropHistory releases every retained version of key. Must be called with
+// the write lock held.
+func (c *InMemoryCache) dropHistory(key string) {
+	for _, entry := range c.history[key] {
+		c.release(entry)
+	}
+	delete(c.history, key)
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"cache-proxy/internal/balancer"
 	"cache-proxy/internal/errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
@@ -14,83 +16,409 @@ import (
 // Config holds the application configuration
 type Config struct {
 	// Server configuration
-	Port    int    `json:"port"`
-	Host    string `json:"host"`
-	Origin  string `json:"origin"`
-	Timeout time.Duration `json:"timeout"`
-	
+	Port    int           `json:"port"`
+	Host    string        `json:"host"`
+	Origin  string        `json:"origin"`
+	Timeout time.Duration `json:"timeout"` // total time allowed for each origin request, including retries and hedges
+
+	// Origin connection timeouts, overridable per upstream. A zero body
+	// timeout leaves reading the body bounded only by Timeout.
+	DialTimeout           time.Duration `json:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout"`
+	BodyTimeout           time.Duration `json:"body_timeout"`
+
+	// Client connection timeouts for the proxy's own listener
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+
+	// DeadlineHeader names a request header in which clients may ask for a
+	// shorter origin timeout, as a duration such as "2s" or in seconds; it
+	// can never raise the configured one. Empty ignores client deadlines.
+	DeadlineHeader string `json:"deadline_header"`
+
 	// Cache configuration
-	CacheSize     int           `json:"cache_size"`
-	CacheTTL      time.Duration `json:"cache_ttl"`
-	ClearCache    bool          `json:"clear_cache"`
-	
+	CacheSize  int           `json:"cache_size"`
+	CacheTTL   time.Duration `json:"cache_ttl"`
+	ClearCache bool          `json:"clear_cache"`
+
+	// CacheAdmission selects the admission filter for new entries: none or tinylfu
+	CacheAdmission string `json:"cache_admission"`
+
+	// CacheEviction selects the default zone's eviction policy: fifo, lru or lfu
+	CacheEviction string `json:"cache_eviction"`
+
+	// CacheVersions is how many versions of each entry are kept for rollback,
+	// including the current one
+	CacheVersions int `json:"cache_versions"`
+
+	// CacheNamespace is folded into every cache key; changing it makes all
+	// previously cached entries unreachable
+	CacheNamespace string `json:"cache_namespace"`
+
+	// Zones, upstreams and routes are loaded from the config file. When an
+	// origin is also given it becomes the "default" upstream and route,
+	// matched after all configured routes.
+	ConfigFile string           `json:"config_file"`
+	Zones      []ZoneConfig     `json:"zones"`
+	Upstreams  []UpstreamConfig `json:"upstreams"`
+	Routes     []RouteConfig    `json:"routes"`
+
+	// Memory pressure configuration; a zero soft limit disables the monitor
+	MemorySoftLimit     int64         `json:"memory_soft_limit"`
+	MemoryLowWater      float64       `json:"memory_low_water"`
+	MemoryCheckInterval time.Duration `json:"memory_check_interval"`
+
+	// CleanupInterval is how often expired entries are purged in the background
+	CleanupInterval time.Duration `json:"cleanup_interval"`
+
+	// Status-aware caching policy; CacheTTL applies to 200, 203 and 204 responses
+	NegativeCacheTTL     time.Duration `json:"negative_cache_ttl"`
+	CacheServerErrors    bool          `json:"cache_server_errors"`
+	ServerErrorCacheTTL  time.Duration `json:"server_error_cache_ttl"`
+	PermanentRedirectTTL time.Duration `json:"permanent_redirect_ttl"`
+	TemporaryRedirectTTL time.Duration `json:"temporary_redirect_ttl"`
+
+	// Refresh-ahead configuration
+	RefreshAheadFraction float64 `json:"refresh_ahead_fraction"`
+	RefreshMinHits       int     `json:"refresh_min_hits"`
+	RefreshConcurrency   int     `json:"refresh_concurrency"`
+
+	// Adaptive TTL configuration; TTLs are stretched by AdaptiveTTLFactor,
+	// up to AdaptiveTTLMax, while origin latency or error rate over the
+	// window exceeds a threshold. Zero thresholds disable that trigger.
+	AdaptiveTTLLatency   time.Duration `json:"adaptive_ttl_latency"`
+	AdaptiveTTLErrorRate float64       `json:"adaptive_ttl_error_rate"`
+	AdaptiveTTLFactor    float64       `json:"adaptive_ttl_factor"`
+	AdaptiveTTLMax       time.Duration `json:"adaptive_ttl_max"`
+	AdaptiveTTLWindow    time.Duration `json:"adaptive_ttl_window"`
+
+	// Circuit breaker configuration, applied to each upstream separately. The
+	// breaker opens when the error rate or mean latency over the window
+	// exceeds a threshold, rejects requests for the cool-down, then lets
+	// BreakerHalfOpenRequests trial requests through. Zero thresholds
+	// disable that trigger. Requests rejected while it is open are only
+	// answered from cache if StaleGrace is set.
+	BreakerErrorRate        float64       `json:"breaker_error_rate"`
+	BreakerLatency          time.Duration `json:"breaker_latency"`
+	BreakerMinRequests      int           `json:"breaker_min_requests"`
+	BreakerWindow           time.Duration `json:"breaker_window"`
+	BreakerCooldown         time.Duration `json:"breaker_cooldown"`
+	BreakerHalfOpenRequests int           `json:"breaker_half_open_requests"`
+
+	// Retry configuration for idempotent requests. Each upstream has its own
+	// budget: over a 10 second window retries may not exceed
+	// RetryBudgetRatio of requests plus RetryBudgetMin.
+	RetryAttempts    int           `json:"retry_attempts"`
+	RetryBackoff     time.Duration `json:"retry_backoff"`
+	RetryMaxBackoff  time.Duration `json:"retry_max_backoff"`
+	RetryOn          []int         `json:"retry_on"`
+	RetryBudgetRatio float64       `json:"retry_budget_ratio"`
+	RetryBudgetMin   int           `json:"retry_budget_min"`
+
+	// RetryBodyLimit is the largest request body buffered so a retry or
+	// hedge can send it again; larger bodies are streamed and sent once
+	RetryBodyLimit int64 `json:"retry_body_limit"`
+
+	// StaleGrace is how long expired entries are kept so they can be served
+	// while an upstream's circuit breaker is open. It is off by default, so
+	// an open breaker fails requests for expired entries until it is set.
+	StaleGrace time.Duration `json:"stale_grace"`
+
+	// Forwarding configuration. ForwardedHeaders picks which of
+	// X-Forwarded-* and RFC 7239 Forwarded are sent to origins; incoming
+	// chains are only appended to when the client is a trusted proxy.
+	// HostHeader either preserves the client's Host or rewrites it to the
+	// origin's.
+	ForwardedHeaders string   `json:"forwarded_headers"`
+	TrustedProxies   []string `json:"trusted_proxies"`
+	HostHeader       string   `json:"host_header"`
+
+	// UpgradeIdleTimeout closes upgraded connections, such as WebSockets,
+	// that carry no traffic in either direction for this long (0 disables)
+	UpgradeIdleTimeout time.Duration `json:"upgrade_idle_timeout"`
+
+	// StreamContentTypes are response media types relayed to the client as
+	// they arrive instead of being read whole; they are never cached
+	StreamContentTypes []string `json:"stream_content_types"`
+
 	// Logging configuration
-	LogLevel      string `json:"log_level"`
-	LogFormat     string `json:"log_format"`
-	
+	LogLevel  string `json:"log_level"`
+	LogFormat string `json:"log_format"`
+
 	// Security configuration
-	EnableCORS    bool     `json:"enable_cors"`
+	EnableCORS     bool     `json:"enable_cors"`
 	AllowedOrigins []string `json:"allowed_origins"`
-	
+
 	// Health check configuration
 	EnableHealthCheck bool `json:"enable_health_check"`
+
+	// Cache warming configuration
+	WarmURLsFile    string  `json:"warm_urls_file"`
+	WarmSitemap     string  `json:"warm_sitemap"`
+	WarmConcurrency int     `json:"warm_concurrency"`
+	WarmRate        float64 `json:"warm_rate"`
+	WarmHost        string  `json:"warm_host"` // Host for URLs given as paths; without it they aren't warmed
+
+	// Admin configuration for PURGE/BAN and cache management
+	AdminToken      string   `json:"admin_token"`
+	AdminAllowedIPs []string `json:"admin_allowed_ips"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
-		Host:              "0.0.0.0",
-		Timeout:           30 * time.Second,
-		CacheSize:         1000,
-		CacheTTL:          5 * time.Minute,
-		LogLevel:          "info",
-		LogFormat:         "json",
-		EnableCORS:        true,
-		AllowedOrigins:    []string{"*"},
-		EnableHealthCheck: true,
+		Host:                    "0.0.0.0",
+		Timeout:                 30 * time.Second,
+		DialTimeout:             5 * time.Second,
+		TLSHandshakeTimeout:     10 * time.Second,
+		ResponseHeaderTimeout:   30 * time.Second,
+		ReadHeaderTimeout:       10 * time.Second,
+		ReadTimeout:             time.Minute,
+		WriteTimeout:            5 * time.Minute,
+		IdleTimeout:             time.Minute,
+		DeadlineHeader:          "X-Request-Timeout",
+		CacheSize:               1000,
+		CacheTTL:                5 * time.Minute,
+		CacheAdmission:          "none",
+		CacheEviction:           "fifo",
+		CacheVersions:           3,
+		MemoryLowWater:          0.8,
+		MemoryCheckInterval:     time.Second,
+		CleanupInterval:         5 * time.Minute,
+		NegativeCacheTTL:        30 * time.Second,
+		ServerErrorCacheTTL:     10 * time.Second,
+		PermanentRedirectTTL:    5 * time.Minute,
+		RefreshMinHits:          10,
+		RefreshConcurrency:      4,
+		AdaptiveTTLFactor:       4,
+		AdaptiveTTLMax:          time.Hour,
+		AdaptiveTTLWindow:       time.Minute,
+		BreakerMinRequests:      20,
+		BreakerWindow:           30 * time.Second,
+		BreakerCooldown:         30 * time.Second,
+		BreakerHalfOpenRequests: 3,
+		RetryAttempts:           2,
+		RetryBackoff:            50 * time.Millisecond,
+		RetryMaxBackoff:         time.Second,
+		RetryOn:                 []int{502, 503, 504},
+		RetryBudgetRatio:        0.2,
+		RetryBudgetMin:          10,
+		RetryBodyLimit:          1 << 20,
+		ForwardedHeaders:        "x-forwarded",
+		HostHeader:              "origin",
+		UpgradeIdleTimeout:      5 * time.Minute,
+		StreamContentTypes:      []string{"text/event-stream", "application/x-ndjson"},
+		LogLevel:                "info",
+		LogFormat:               "json",
+		EnableCORS:              true,
+		AllowedOrigins:          []string{"*"},
+		EnableHealthCheck:       true,
+		WarmConcurrency:         4,
+		WarmRate:                10,
+		AdminAllowedIPs:         []string{"127.0.0.1", "::1"},
 	}
 }
 
 // ParseFlags parses command line flags and environment variables
 func ParseFlags() (*Config, error) {
 	config := DefaultConfig()
-	
+
 	var (
-		port              = flag.Int("port", getEnvInt("PROXY_PORT", 0), "Port number where proxy runs")
-		host              = flag.String("host", getEnvString("PROXY_HOST", config.Host), "Host to bind the server")
-		origin            = flag.String("origin", getEnvString("PROXY_ORIGIN", ""), "Origin server to forward requests")
-		timeout           = flag.Duration("timeout", getEnvDuration("PROXY_TIMEOUT", config.Timeout), "Request timeout")
-		cacheSize         = flag.Int("cache-size", getEnvInt("PROXY_CACHE_SIZE", config.CacheSize), "Maximum number of cache entries")
-		cacheTTL          = flag.Duration("cache-ttl", getEnvDuration("PROXY_CACHE_TTL", config.CacheTTL), "Cache time-to-live")
-		clearCache        = flag.Bool("clear-cache", false, "Clear cache and exit")
-		logLevel          = flag.String("log-level", getEnvString("PROXY_LOG_LEVEL", config.LogLevel), "Log level (debug, info, warn, error)")
-		logFormat         = flag.String("log-format", getEnvString("PROXY_LOG_FORMAT", config.LogFormat), "Log format (json, text)")
-		enableCORS        = flag.Bool("enable-cors", getEnvBool("PROXY_ENABLE_CORS", config.EnableCORS), "Enable CORS headers")
-		allowedOrigins    = flag.String("allowed-origins", getEnvString("PROXY_ALLOWED_ORIGINS", strings.Join(config.AllowedOrigins, ",")), "Comma-separated list of allowed origins")
-		enableHealthCheck = flag.Bool("enable-health-check", getEnvBool("PROXY_ENABLE_HEALTH_CHECK", config.EnableHealthCheck), "Enable health check endpoint")
+		port                  = flag.Int("port", getEnvInt("PROXY_PORT", 0), "Port number where proxy runs")
+		host                  = flag.String("host", getEnvString("PROXY_HOST", config.Host), "Host to bind the server")
+		origin                = flag.String("origin", getEnvString("PROXY_ORIGIN", ""), "Origin server to forward requests")
+		timeout               = flag.Duration("timeout", getEnvDuration("PROXY_TIMEOUT", config.Timeout), "Total timeout for each origin request, including retries and hedges")
+		dialTimeout           = flag.Duration("dial-timeout", getEnvDuration("PROXY_DIAL_TIMEOUT", config.DialTimeout), "Timeout for connecting to an origin")
+		tlsHandshakeTimeout   = flag.Duration("tls-handshake-timeout", getEnvDuration("PROXY_TLS_HANDSHAKE_TIMEOUT", config.TLSHandshakeTimeout), "Timeout for the TLS handshake with an origin")
+		responseHeaderTimeout = flag.Duration("response-header-timeout", getEnvDuration("PROXY_RESPONSE_HEADER_TIMEOUT", config.ResponseHeaderTimeout), "Timeout for an origin to send response headers once the request is written")
+		bodyTimeout           = flag.Duration("body-timeout", getEnvDuration("PROXY_BODY_TIMEOUT", 0), "Timeout for reading an origin response body (0 leaves it to --timeout)")
+		readHeaderTimeout     = flag.Duration("read-header-timeout", getEnvDuration("PROXY_READ_HEADER_TIMEOUT", config.ReadHeaderTimeout), "Timeout for clients to send request headers")
+		readTimeout           = flag.Duration("read-timeout", getEnvDuration("PROXY_READ_TIMEOUT", config.ReadTimeout), "Timeout for clients to send the whole request")
+		writeTimeout          = flag.Duration("write-timeout", getEnvDuration("PROXY_WRITE_TIMEOUT", config.WriteTimeout), "Timeout for handling a request and writing the response")
+		idleTimeout           = flag.Duration("idle-timeout", getEnvDuration("PROXY_IDLE_TIMEOUT", config.IdleTimeout), "How long idle client keep-alive connections are kept open")
+		deadlineHeader        = flag.String("deadline-header", getEnvString("PROXY_DEADLINE_HEADER", config.DeadlineHeader), "Request header in which clients may ask for a shorter origin timeout (empty ignores it)")
+		cacheSize             = flag.Int("cache-size", getEnvInt("PROXY_CACHE_SIZE", config.CacheSize), "Maximum number of cache entries")
+		cacheTTL              = flag.Duration("cache-ttl", getEnvDuration("PROXY_CACHE_TTL", config.CacheTTL), "Cache time-to-live")
+		clearCache            = flag.Bool("clear-cache", false, "Clear cache and exit")
+		configFile            = flag.String("config", getEnvString("PROXY_CONFIG", ""), "JSON file with cache zones, upstreams and routes")
+		cacheEviction         = flag.String("cache-eviction", getEnvString("PROXY_CACHE_EVICTION", config.CacheEviction), "Cache eviction policy (fifo, lru, lfu)")
+		cacheVersions         = flag.Int("cache-versions", getEnvInt("PROXY_CACHE_VERSIONS", config.CacheVersions), "Versions of each cache entry kept for rollback, including the current one")
+		cacheNamespace        = flag.String("cache-namespace", getEnvString("PROXY_CACHE_NAMESPACE", ""), "Namespace folded into every cache key; change it to invalidate the whole cache")
+		cacheAdmission        = flag.String("cache-admission", getEnvString("PROXY_CACHE_ADMISSION", config.CacheAdmission), "Cache admission policy (none, tinylfu)")
+		memorySoftLimit       = flag.String("memory-soft-limit", getEnvString("PROXY_MEMORY_SOFT_LIMIT", ""), "Heap size that triggers cache eviction, e.g. 512MiB (empty disables)")
+		memoryLowWater        = flag.Float64("memory-low-water", getEnvFloat("PROXY_MEMORY_LOW_WATER", config.MemoryLowWater), "Fraction of the soft limit to evict down to under memory pressure")
+		memoryCheckInterval   = flag.Duration("memory-check-interval", getEnvDuration("PROXY_MEMORY_CHECK_INTERVAL", config.MemoryCheckInterval), "How often heap usage is sampled")
+		cleanupInterval       = flag.Duration("cleanup-interval", getEnvDuration("PROXY_CLEANUP_INTERVAL", config.CleanupInterval), "How often expired cache entries are purged")
+		negativeCacheTTL      = flag.Duration("negative-cache-ttl", getEnvDuration("PROXY_NEGATIVE_CACHE_TTL", config.NegativeCacheTTL), "Time-to-live for 404 and 410 responses (0 disables)")
+		cacheServerErrors     = flag.Bool("cache-server-errors", getEnvBool("PROXY_CACHE_SERVER_ERRORS", config.CacheServerErrors), "Cache 5xx responses")
+		serverErrorCacheTTL   = flag.Duration("server-error-cache-ttl", getEnvDuration("PROXY_SERVER_ERROR_CACHE_TTL", config.ServerErrorCacheTTL), "Time-to-live for 5xx responses when they are cached")
+		permanentRedirectTTL  = flag.Duration("permanent-redirect-ttl", getEnvDuration("PROXY_PERMANENT_REDIRECT_TTL", config.PermanentRedirectTTL), "Time-to-live for 301 and 308 responses (0 disables)")
+		temporaryRedirectTTL  = flag.Duration("temporary-redirect-ttl", getEnvDuration("PROXY_TEMPORARY_REDIRECT_TTL", config.TemporaryRedirectTTL), "Time-to-live for 302 and 307 responses (0 disables)")
+		refreshAhead          = flag.Float64("refresh-ahead", getEnvFloat("PROXY_REFRESH_AHEAD", config.RefreshAheadFraction), "Fraction of TTL after which hot entries are refreshed in the background (0 disables)")
+		refreshMinHits        = flag.Int("refresh-min-hits", getEnvInt("PROXY_REFRESH_MIN_HITS", config.RefreshMinHits), "Hits an entry needs within its TTL to be refreshed ahead of expiry")
+		refreshConcurrency    = flag.Int("refresh-concurrency", getEnvInt("PROXY_REFRESH_CONCURRENCY", config.RefreshConcurrency), "Maximum concurrent refresh-ahead requests")
+		adaptiveTTLLatency    = flag.Duration("adaptive-ttl-latency", getEnvDuration("PROXY_ADAPTIVE_TTL_LATENCY", 0), "Mean origin latency that stretches cache TTLs (0 disables)")
+		adaptiveTTLErrorRate  = flag.Float64("adaptive-ttl-error-rate", getEnvFloat("PROXY_ADAPTIVE_TTL_ERROR_RATE", 0), "Origin error rate between 0 and 1 that stretches cache TTLs (0 disables)")
+		adaptiveTTLFactor     = flag.Float64("adaptive-ttl-factor", getEnvFloat("PROXY_ADAPTIVE_TTL_FACTOR", config.AdaptiveTTLFactor), "Multiplier applied to TTLs while the origin is slow or failing")
+		adaptiveTTLMax        = flag.Duration("adaptive-ttl-max", getEnvDuration("PROXY_ADAPTIVE_TTL_MAX", config.AdaptiveTTLMax), "Cap on stretched TTLs")
+		adaptiveTTLWindow     = flag.Duration("adaptive-ttl-window", getEnvDuration("PROXY_ADAPTIVE_TTL_WINDOW", config.AdaptiveTTLWindow), "Rolling window over which origin latency and errors are measured")
+		breakerErrorRate      = flag.Float64("breaker-error-rate", getEnvFloat("PROXY_BREAKER_ERROR_RATE", 0), "Upstream error rate between 0 and 1 that opens its circuit breaker (0 disables; see --stale-grace to serve expired entries while open)")
+		breakerLatency        = flag.Duration("breaker-latency", getEnvDuration("PROXY_BREAKER_LATENCY", 0), "Mean upstream latency that opens its circuit breaker (0 disables; see --stale-grace to serve expired entries while open)")
+		breakerMinRequests    = flag.Int("breaker-min-requests", getEnvInt("PROXY_BREAKER_MIN_REQUESTS", config.BreakerMinRequests), "Requests the breaker window needs before it can open")
+		breakerWindow         = flag.Duration("breaker-window", getEnvDuration("PROXY_BREAKER_WINDOW", config.BreakerWindow), "Rolling window over which circuit breakers measure errors and latency")
+		breakerCooldown       = flag.Duration("breaker-cooldown", getEnvDuration("PROXY_BREAKER_COOLDOWN", config.BreakerCooldown), "How long an open circuit breaker rejects requests before trying the upstream again")
+		breakerHalfOpen       = flag.Int("breaker-half-open-requests", getEnvInt("PROXY_BREAKER_HALF_OPEN_REQUESTS", config.BreakerHalfOpenRequests), "Trial requests a half-open circuit breaker needs to succeed before closing")
+		retryAttempts         = flag.Int("retry-attempts", getEnvInt("PROXY_RETRY_ATTEMPTS", config.RetryAttempts), "Retries for failed idempotent origin requests (0 disables)")
+		retryBackoff          = flag.Duration("retry-backoff", getEnvDuration("PROXY_RETRY_BACKOFF", config.RetryBackoff), "Backoff before the first retry, doubled for each one after")
+		retryMaxBackoff       = flag.Duration("retry-max-backoff", getEnvDuration("PROXY_RETRY_MAX_BACKOFF", config.RetryMaxBackoff), "Cap on retry backoff; a longer Retry-After is not waited for")
+		retryOn               = flag.String("retry-on", getEnvString("PROXY_RETRY_ON", joinInts(config.RetryOn)), "Comma-separated origin response statuses that are retried")
+		retryBudgetRatio      = flag.Float64("retry-budget-ratio", getEnvFloat("PROXY_RETRY_BUDGET_RATIO", config.RetryBudgetRatio), "Retries allowed per request to an upstream over a 10 second window")
+		retryBudgetMin        = flag.Int("retry-budget-min", getEnvInt("PROXY_RETRY_BUDGET_MIN", config.RetryBudgetMin), "Retries always allowed per upstream over a 10 second window")
+		retryBodyLimit        = flag.String("retry-body-limit", getEnvString("PROXY_RETRY_BODY_LIMIT", "1MiB"), "Largest request body buffered for retries and hedging; larger bodies are streamed and not retried")
+		staleGrace            = flag.Duration("stale-grace", getEnvDuration("PROXY_STALE_GRACE", 0), "How long expired entries are kept to serve while an upstream's circuit breaker is open (0 disables stale fallback)")
+		forwardedHeaders      = flag.String("forwarded-headers", getEnvString("PROXY_FORWARDED_HEADERS", config.ForwardedHeaders), "Forwarding headers sent to origins (none, x-forwarded, forwarded, both)")
+		trustedProxies        = flag.String("trusted-proxies", getEnvString("PROXY_TRUSTED_PROXIES", ""), "Comma-separated IPs or CIDRs whose forwarding headers are kept and appended to")
+		hostHeader            = flag.String("host-header", getEnvString("PROXY_HOST_HEADER", config.HostHeader), "Host header sent to origins: preserve the client's or rewrite to the origin's (preserve, origin)")
+		upgradeIdleTimeout    = flag.Duration("upgrade-idle-timeout", getEnvDuration("PROXY_UPGRADE_IDLE_TIMEOUT", config.UpgradeIdleTimeout), "How long an upgraded connection such as a WebSocket may go without traffic (0 disables)")
+		streamContentTypes    = flag.String("stream-content-types", getEnvString("PROXY_STREAM_CONTENT_TYPES", strings.Join(config.StreamContentTypes, ",")), "Comma-separated response content types streamed to clients uncached")
+		logLevel              = flag.String("log-level", getEnvString("PROXY_LOG_LEVEL", config.LogLevel), "Log level (debug, info, warn, error)")
+		logFormat             = flag.String("log-format", getEnvString("PROXY_LOG_FORMAT", config.LogFormat), "Log format (json, text)")
+		enableCORS            = flag.Bool("enable-cors", getEnvBool("PROXY_ENABLE_CORS", config.EnableCORS), "Enable CORS headers")
+		allowedOrigins        = flag.String("allowed-origins", getEnvString("PROXY_ALLOWED_ORIGINS", strings.Join(config.AllowedOrigins, ",")), "Comma-separated list of allowed origins")
+		enableHealthCheck     = flag.Bool("enable-health-check", getEnvBool("PROXY_ENABLE_HEALTH_CHECK", config.EnableHealthCheck), "Enable health check endpoint")
+		warmURLsFile          = flag.String("warm-urls-file", getEnvString("PROXY_WARM_URLS_FILE", ""), "File with one URL per line to warm the cache with at startup")
+		warmSitemap           = flag.String("warm-sitemap", getEnvString("PROXY_WARM_SITEMAP", ""), "Sitemap URL or file to warm the cache with at startup")
+		warmConcurrency       = flag.Int("warm-concurrency", getEnvInt("PROXY_WARM_CONCURRENCY", config.WarmConcurrency), "Concurrent requests used for cache warming")
+		warmRate              = flag.Float64("warm-rate", getEnvFloat("PROXY_WARM_RATE", config.WarmRate), "Cache warming requests per second (0 for unlimited)")
+		warmHost              = flag.String("warm-host", getEnvString("PROXY_WARM_HOST", ""), "Host that cache warming requests paths for; paths are not warmed without it")
+		adminToken            = flag.String("admin-token", getEnvString("PROXY_ADMIN_TOKEN", ""), "Token accepted in X-Admin-Token for PURGE, BAN and admin endpoints")
+		adminAllowedIPs       = flag.String("admin-allowed-ips", getEnvString("PROXY_ADMIN_ALLOWED_IPS", strings.Join(config.AdminAllowedIPs, ",")), "Comma-separated IPs or CIDRs allowed to PURGE, BAN and use admin endpoints")
 	)
-	
+
 	flag.Parse()
 
 	config.Port = *port
 	config.Host = *host
 	config.Origin = *origin
 	config.Timeout = *timeout
+	config.DialTimeout = *dialTimeout
+	config.TLSHandshakeTimeout = *tlsHandshakeTimeout
+	config.ResponseHeaderTimeout = *responseHeaderTimeout
+	config.BodyTimeout = *bodyTimeout
+	config.ReadHeaderTimeout = *readHeaderTimeout
+	config.ReadTimeout = *readTimeout
+	config.WriteTimeout = *writeTimeout
+	config.IdleTimeout = *idleTimeout
+	config.DeadlineHeader = *deadlineHeader
 	config.CacheSize = *cacheSize
 	config.CacheTTL = *cacheTTL
 	config.ClearCache = *clearCache
+	config.ConfigFile = *configFile
+	config.CacheEviction = *cacheEviction
+	config.CacheAdmission = *cacheAdmission
+	config.CacheVersions = *cacheVersions
+	config.CacheNamespace = *cacheNamespace
+	config.MemoryLowWater = *memoryLowWater
+	config.MemoryCheckInterval = *memoryCheckInterval
+	config.CleanupInterval = *cleanupInterval
+	config.NegativeCacheTTL = *negativeCacheTTL
+	config.CacheServerErrors = *cacheServerErrors
+	config.ServerErrorCacheTTL = *serverErrorCacheTTL
+	config.PermanentRedirectTTL = *permanentRedirectTTL
+	config.TemporaryRedirectTTL = *temporaryRedirectTTL
+	config.RefreshAheadFraction = *refreshAhead
+	config.RefreshMinHits = *refreshMinHits
+	config.RefreshConcurrency = *refreshConcurrency
+	config.AdaptiveTTLLatency = *adaptiveTTLLatency
+	config.AdaptiveTTLErrorRate = *adaptiveTTLErrorRate
+	config.AdaptiveTTLFactor = *adaptiveTTLFactor
+	config.AdaptiveTTLMax = *adaptiveTTLMax
+	config.AdaptiveTTLWindow = *adaptiveTTLWindow
+	config.BreakerErrorRate = *breakerErrorRate
+	config.BreakerLatency = *breakerLatency
+	config.BreakerMinRequests = *breakerMinRequests
+	config.BreakerWindow = *breakerWindow
+	config.BreakerCooldown = *breakerCooldown
+	config.BreakerHalfOpenRequests = *breakerHalfOpen
+	config.RetryAttempts = *retryAttempts
+	config.RetryBackoff = *retryBackoff
+	config.RetryMaxBackoff = *retryMaxBackoff
+	config.RetryBudgetRatio = *retryBudgetRatio
+	config.RetryBudgetMin = *retryBudgetMin
+	config.StaleGrace = *staleGrace
+	config.ForwardedHeaders = *forwardedHeaders
+	config.HostHeader = *hostHeader
+	config.UpgradeIdleTimeout = *upgradeIdleTimeout
 	config.LogLevel = *logLevel
 	config.LogFormat = *logFormat
 	config.EnableCORS = *enableCORS
 	config.EnableHealthCheck = *enableHealthCheck
-	
+	config.WarmURLsFile = *warmURLsFile
+	config.WarmSitemap = *warmSitemap
+	config.WarmConcurrency = *warmConcurrency
+	config.WarmRate = *warmRate
+	config.WarmHost = *warmHost
+	config.AdminToken = *adminToken
+
 	if *allowedOrigins != "" {
 		config.AllowedOrigins = strings.Split(*allowedOrigins, ",")
 	}
 
+	if config.ConfigFile != "" {
+		if err := loadFile(config.ConfigFile, config); err != nil {
+			return config, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_CONFIG_FILE", err.Error(), 400)
+		}
+	}
+
+	if *memorySoftLimit != "" {
+		limit, err := parseByteSize(*memorySoftLimit)
+		if err != nil {
+			return config, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_MEMORY_SOFT_LIMIT", "memory soft limit must be a size such as 512MiB", 400)
+		}
+		config.MemorySoftLimit = limit
+	}
+
+	limit, err := parseByteSize(*retryBodyLimit)
+	if err != nil {
+		return config, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_RETRY_BODY_LIMIT", "retry body limit must be a size such as 1MiB", 400)
+	}
+	config.RetryBodyLimit = limit
+
+	config.RetryOn = nil
+	for _, field := range strings.Split(*retryOn, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		status, err := strconv.Atoi(field)
+		if err != nil {
+			return config, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_RETRY_ON", "retry-on must be a comma-separated list of HTTP statuses", 400)
+		}
+		config.RetryOn = append(config.RetryOn, status)
+	}
+
+	config.StreamContentTypes = nil
+	if *streamContentTypes != "" {
+		config.StreamContentTypes = strings.Split(*streamContentTypes, ",")
+	}
+
+	if *trustedProxies != "" {
+		config.TrustedProxies = strings.Split(*trustedProxies, ",")
+	}
+
+	config.AdminAllowedIPs = nil
+	if *adminAllowedIPs != "" {
+		config.AdminAllowedIPs = strings.Split(*adminAllowedIPs, ",")
+	}
+
 	return config, config.Validate()
 }
 
+// validHostHeaders are the Host header policies
+var validHostHeaders = map[string]bool{"preserve": true, "origin": true}
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	if c.ClearCache {
@@ -101,22 +429,142 @@ func (c *Config) Validate() error {
 		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_PORT", "port must be between 1 and 65535", 400)
 	}
 
-	if c.Origin == "" {
-		return errors.Wrap(nil, errors.ErrorTypeValidation, "MISSING_ORIGIN", "origin server URL is required", 400)
+	if c.Origin == "" && len(c.Routes) == 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "MISSING_ORIGIN", "origin server URL or routes in the config file are required", 400)
 	}
 
-	if _, err := url.Parse(c.Origin); err != nil {
-		return errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ORIGIN_URL", "origin server URL is invalid", 400)
+	if c.Origin != "" {
+		if _, err := url.Parse(c.Origin); err != nil {
+			return errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ORIGIN_URL", "origin server URL is invalid", 400)
+		}
 	}
 
 	if c.Timeout <= 0 {
 		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_TIMEOUT", "timeout must be positive", 400)
 	}
 
+	if c.DialTimeout < 0 || c.TLSHandshakeTimeout < 0 || c.ResponseHeaderTimeout < 0 || c.BodyTimeout < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_TIMEOUT", "dial, TLS handshake, response header and body timeouts must not be negative", 400)
+	}
+
+	if c.ReadHeaderTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_TIMEOUT", "server read header, read, write and idle timeouts must not be negative", 400)
+	}
+
+	if c.UpgradeIdleTimeout < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_TIMEOUT", "upgrade idle timeout must not be negative", 400)
+	}
+
 	if c.CacheSize <= 0 {
 		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CACHE_SIZE", "cache size must be positive", 400)
 	}
 
+	if c.CacheVersions <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CACHE_VERSIONS", "cache versions must be positive", 400)
+	}
+
+	validAdmissions := map[string]bool{"none": true, "tinylfu": true}
+	if !validAdmissions[c.CacheAdmission] {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CACHE_ADMISSION", "cache admission must be none or tinylfu", 400)
+	}
+
+	validEvictions := map[string]bool{"fifo": true, "lru": true, "lfu": true}
+	if !validEvictions[c.CacheEviction] {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CACHE_EVICTION", "cache eviction must be fifo, lru or lfu", 400)
+	}
+
+	zoneNames := map[string]bool{"default": true}
+	for _, zone := range c.Zones {
+		if zone.Name == "" || zoneNames[zone.Name] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ZONE", fmt.Sprintf("zone name %q is empty, reserved or duplicated", zone.Name), 400)
+		}
+		zoneNames[zone.Name] = true
+
+		if zone.MaxSize < 0 || zone.TTL < 0 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ZONE", fmt.Sprintf("zone %q max_size and ttl must not be negative", zone.Name), 400)
+		}
+		if zone.Eviction != "" && !validEvictions[zone.Eviction] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ZONE", fmt.Sprintf("zone %q eviction must be fifo, lru or lfu", zone.Name), 400)
+		}
+		if zone.Admission != "" && !validAdmissions[zone.Admission] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ZONE", fmt.Sprintf("zone %q admission must be none or tinylfu", zone.Name), 400)
+		}
+	}
+
+	if err := c.validateRoutes(zoneNames); err != nil {
+		return err
+	}
+
+	if c.MemorySoftLimit < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_MEMORY_SOFT_LIMIT", "memory soft limit must not be negative", 400)
+	}
+
+	if c.MemoryLowWater <= 0 || c.MemoryLowWater >= 1 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_MEMORY_LOW_WATER", "memory low-water mark must be between 0 and 1", 400)
+	}
+
+	if c.MemoryCheckInterval <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_MEMORY_CHECK_INTERVAL", "memory check interval must be positive", 400)
+	}
+
+	if c.CleanupInterval <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CLEANUP_INTERVAL", "cleanup interval must be positive", 400)
+	}
+
+	if c.CacheTTL < 0 || c.NegativeCacheTTL < 0 || c.ServerErrorCacheTTL < 0 || c.PermanentRedirectTTL < 0 || c.TemporaryRedirectTTL < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_CACHE_TTL", "cache TTLs must not be negative", 400)
+	}
+
+	if c.RefreshAheadFraction < 0 || c.RefreshAheadFraction >= 1 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_REFRESH_AHEAD", "refresh-ahead fraction must be between 0 and 1", 400)
+	}
+
+	if c.RefreshConcurrency <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_REFRESH_CONCURRENCY", "refresh concurrency must be positive", 400)
+	}
+
+	if c.AdaptiveTTLLatency < 0 || c.AdaptiveTTLMax < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ADAPTIVE_TTL", "adaptive TTL latency and max must not be negative", 400)
+	}
+
+	if c.AdaptiveTTLErrorRate < 0 || c.AdaptiveTTLErrorRate > 1 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ADAPTIVE_TTL", "adaptive TTL error rate must be between 0 and 1", 400)
+	}
+
+	if c.AdaptiveTTLFactor < 1 || c.AdaptiveTTLWindow <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ADAPTIVE_TTL", "adaptive TTL factor must be at least 1 and window must be positive", 400)
+	}
+
+	if c.BreakerErrorRate < 0 || c.BreakerErrorRate > 1 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_BREAKER", "breaker error rate must be between 0 and 1", 400)
+	}
+
+	if c.BreakerLatency < 0 || c.BreakerMinRequests <= 0 || c.BreakerWindow <= 0 || c.BreakerCooldown <= 0 || c.BreakerHalfOpenRequests <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_BREAKER", "breaker latency must not be negative; min requests, window, cool-down and half-open requests must be positive", 400)
+	}
+
+	if c.RetryAttempts < 0 || c.RetryBackoff < 0 || c.RetryMaxBackoff < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_RETRY", "retry attempts and backoffs must not be negative", 400)
+	}
+
+	for _, status := range c.RetryOn {
+		if status < 100 || status > 599 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_RETRY_ON", fmt.Sprintf("retry-on status %d is not an HTTP status", status), 400)
+		}
+	}
+
+	if c.RetryBudgetRatio < 0 || c.RetryBudgetMin < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_RETRY_BUDGET", "retry budget ratio and minimum must not be negative", 400)
+	}
+
+	if c.RetryBodyLimit < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_RETRY_BODY_LIMIT", "retry body limit must not be negative", 400)
+	}
+
+	if c.StaleGrace < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_STALE_GRACE", "stale grace must not be negative", 400)
+	}
+
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLogLevels[c.LogLevel] {
 		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_LOG_LEVEL", "log level must be one of: debug, info, warn, error", 400)
@@ -127,6 +575,41 @@ func (c *Config) Validate() error {
 		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_LOG_FORMAT", "log format must be json or text", 400)
 	}
 
+	if c.WarmConcurrency <= 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_WARM_CONCURRENCY", "warm concurrency must be positive", 400)
+	}
+
+	if c.WarmRate < 0 {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_WARM_RATE", "warm rate must not be negative", 400)
+	}
+
+	validForwardedHeaders := map[string]bool{"none": true, "x-forwarded": true, "forwarded": true, "both": true}
+	if !validForwardedHeaders[c.ForwardedHeaders] {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_FORWARDED_HEADERS", "forwarded headers must be none, x-forwarded, forwarded or both", 400)
+	}
+
+	if !validHostHeaders[c.HostHeader] {
+		return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_HOST_HEADER", "host header must be preserve or origin", 400)
+	}
+
+	for _, entry := range c.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_TRUSTED_PROXY", "trusted proxies must be IP addresses or CIDR ranges", 400)
+			}
+		}
+	}
+
+	for _, entry := range c.AdminAllowedIPs {
+		entry = strings.TrimSpace(entry)
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ADMIN_ALLOWED_IP", "admin allowed IPs must be IP addresses or CIDR ranges", 400)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -165,6 +648,44 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// parseByteSize parses sizes such as "1048576", "512MiB", "2GB" or "64k"
+func parseByteSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30},
+		{"kb", 1000}, {"mb", 1000 * 1000}, {"gb", 1000 * 1000 * 1000},
+		{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"b", 1},
+	}
+
+	lower := strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSpace(strings.TrimSuffix(lower, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(lower, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -173,3 +694,86 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// joinInts formats a list of integers as a comma-separated flag default
+func joinInts(values []int) string {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = strconv.Itoa(value)
+	}
+	return strings.Join(fields, ",")
+}
+
+// validateRoutes checks upstream and route names, URLs and references
+func (c *Config) validateRoutes(zoneNames map[string]bool) error {
+	upstreamNames := map[string]bool{"default": true}
+	for _, upstream := range c.Upstreams {
+		if upstream.Name == "" || upstreamNames[upstream.Name] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream name %q is empty, reserved or duplicated", upstream.Name), 400)
+		}
+		upstreamNames[upstream.Name] = true
+
+		if (upstream.URL == "") == (len(upstream.Targets) == 0) {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q needs either a url or targets", upstream.Name), 400)
+		}
+		targets := upstream.Targets
+		if upstream.URL != "" {
+			targets = []TargetConfig{{URL: upstream.URL}}
+		}
+		for _, target := range targets {
+			parsed, err := url.Parse(target.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q target %q must be an http(s) URL", upstream.Name, target.URL), 400)
+			}
+			if target.Weight < 0 {
+				return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q target %q weight must not be negative", upstream.Name, target.URL), 400)
+			}
+		}
+		if upstream.Strategy != "" && !balancer.ValidStrategy(upstream.Strategy) {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q strategy must be round_robin, weighted_round_robin, least_connections, random_two_choices or consistent_hash", upstream.Name), 400)
+		}
+		if upstream.Timeout < 0 || upstream.CacheTTL < 0 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q timeout and cache_ttl must not be negative", upstream.Name), 400)
+		}
+		if upstream.DialTimeout < 0 || upstream.TLSHandshakeTimeout < 0 || upstream.ResponseHeaderTimeout < 0 || upstream.BodyTimeout < 0 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q timeouts must not be negative", upstream.Name), 400)
+		}
+		if upstream.HostHeader != "" && !validHostHeaders[upstream.HostHeader] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q host_header must be preserve or origin", upstream.Name), 400)
+		}
+		if upstream.Zone != "" && !zoneNames[upstream.Zone] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_UPSTREAM", fmt.Sprintf("upstream %q references unknown zone %q", upstream.Name, upstream.Zone), 400)
+		}
+
+		check := upstream.HealthCheck
+		if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_HEALTH_CHECK", fmt.Sprintf("upstream %q health check path must start with /", upstream.Name), 400)
+		}
+		if check.Interval < 0 || check.Timeout < 0 || check.HealthyThreshold < 0 || check.UnhealthyThreshold < 0 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_HEALTH_CHECK", fmt.Sprintf("upstream %q health check interval, timeout and thresholds must not be negative", upstream.Name), 400)
+		}
+		if check.ExpectedStatus != 0 && (check.ExpectedStatus < 100 || check.ExpectedStatus > 599) {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_HEALTH_CHECK", fmt.Sprintf("upstream %q health check expected_status must be an HTTP status", upstream.Name), 400)
+		}
+		if upstream.OutlierDetection.ConsecutiveErrors < 0 || upstream.OutlierDetection.EjectionTime < 0 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_OUTLIER_DETECTION", fmt.Sprintf("upstream %q outlier detection settings must not be negative", upstream.Name), 400)
+		}
+	}
+
+	routeNames := map[string]bool{"default": true}
+	for _, route := range c.Routes {
+		if route.Name == "" || routeNames[route.Name] {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ROUTE", fmt.Sprintf("route name %q is empty, reserved or duplicated", route.Name), 400)
+		}
+		routeNames[route.Name] = true
+
+		if !upstreamNames[route.Upstream] || (route.Upstream == "default" && c.Origin == "") {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_ROUTE", fmt.Sprintf("route %q references unknown upstream %q", route.Name, route.Upstream), 400)
+		}
+		if route.Hedge.Percentile < 0 || route.Hedge.Percentile >= 1 || route.Hedge.MinDelay < 0 || route.Hedge.Budget < 0 || route.Hedge.Budget > 1 {
+			return errors.Wrap(nil, errors.ErrorTypeValidation, "INVALID_HEDGE", fmt.Sprintf("route %q hedge percentile must be below 1, budget between 0 and 1 and min_delay not negative", route.Name), 400)
+		}
+	}
+
+	return nil
+}
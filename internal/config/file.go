@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration that reads from JSON strings such as "30s"
+// or from a number of nanoseconds
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(time.Duration(v))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(data))
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ZoneConfig defines a named cache zone with its own size, TTL and eviction
+// policy, and the rules that route requests to it
+type ZoneConfig struct {
+	Name      string   `json:"name"`
+	MaxSize   int      `json:"max_size"`
+	TTL       Duration `json:"ttl"`
+	Eviction  string   `json:"eviction"`
+	Admission string   `json:"admission"`
+	Namespace string   `json:"namespace"`
+
+	// A request matches when its host is listed (or no hosts are given) and
+	// its path has one of the prefixes or extensions (or neither is given)
+	Hosts        []string `json:"hosts"`
+	PathPrefixes []string `json:"path_prefixes"`
+	Extensions   []string `json:"extensions"`
+}
+
+// HeaderRules rewrites headers: Remove runs first, then Set replaces and
+// Add appends values
+type HeaderRules struct {
+	Set    map[string]string `json:"set"`
+	Add    map[string]string `json:"add"`
+	Remove []string          `json:"remove"`
+}
+
+// TargetConfig is one member of an upstream pool
+type TargetConfig struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// HealthCheckConfig configures active probes of every target in an
+// upstream; an empty path disables them
+type HealthCheckConfig struct {
+	Path               string   `json:"path"`
+	Interval           Duration `json:"interval"`
+	Timeout            Duration `json:"timeout"`
+	ExpectedStatus     int      `json:"expected_status"`     // 0 accepts any 2xx or 3xx
+	HealthyThreshold   int      `json:"healthy_threshold"`   // consecutive passes that restore a target
+	UnhealthyThreshold int      `json:"unhealthy_threshold"` // consecutive failures that remove a target
+}
+
+// OutlierConfig ejects a target from rotation for EjectionTime after
+// ConsecutiveErrors failed requests in a row; zero disables it
+type OutlierConfig struct {
+	ConsecutiveErrors int      `json:"consecutive_errors"`
+	EjectionTime      Duration `json:"ejection_time"`
+}
+
+// UpstreamConfig defines a named origin, either a single URL or a pool of
+// targets, with its own timeout, cache settings and header rules
+type UpstreamConfig struct {
+	Name     string         `json:"name"`
+	URL      string         `json:"url"`
+	Targets  []TargetConfig `json:"targets"`
+	Strategy string         `json:"strategy"` // round_robin, weighted_round_robin, least_connections, random_two_choices or consistent_hash
+
+	// Timeouts; zero uses the proxy-wide setting. Timeout bounds the whole
+	// request including retries and hedges, the others one phase of it.
+	Timeout               Duration `json:"timeout"`
+	DialTimeout           Duration `json:"dial_timeout"`
+	TLSHandshakeTimeout   Duration `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"`
+	BodyTimeout           Duration `json:"body_timeout"`
+
+	// Cache settings: Zone pins the upstream's responses to a cache zone,
+	// CacheTTL overrides the zone's TTL for 200, 203 and 204 responses and NoCache
+	// disables caching entirely
+	Zone     string   `json:"zone"`
+	CacheTTL Duration `json:"cache_ttl"`
+	NoCache  bool     `json:"no_cache"`
+
+	// HostHeader overrides the proxy-wide Host header policy
+	HostHeader string `json:"host_header"` // preserve or origin
+
+	RequestHeaders  HeaderRules `json:"request_headers"`
+	ResponseHeaders HeaderRules `json:"response_headers"`
+
+	HealthCheck      HealthCheckConfig `json:"health_check"`
+	OutlierDetection OutlierConfig     `json:"outlier_detection"`
+}
+
+// HedgeConfig sends a second request to another target when the first
+// hasn't answered within Percentile of the route's recent latencies. At most
+// Budget of requests are hedged; a zero percentile disables hedging.
+type HedgeConfig struct {
+	Percentile float64  `json:"percentile"` // e.g. 0.95
+	MinDelay   Duration `json:"min_delay"`
+	Budget     float64  `json:"budget"` // defaults to 0.1
+}
+
+// RouteConfig maps requests to an upstream by host name and path prefix.
+// Routes are matched in order; empty hosts or prefixes match anything.
+type RouteConfig struct {
+	Name         string      `json:"name"`
+	Upstream     string      `json:"upstream"`
+	Hosts        []string    `json:"hosts"`
+	PathPrefixes []string    `json:"path_prefixes"`
+	StripPrefix  bool        `json:"strip_prefix"` // remove the matched prefix before forwarding
+	Hedge        HedgeConfig `json:"hedge"`        // for idempotent requests only
+	Stream       bool        `json:"stream"`       // relay responses as they arrive, never cached
+}
+
+// fileConfig is the layout of the JSON file passed with --config, holding
+// the structured settings that don't fit in flags
+type fileConfig struct {
+	Zones     []ZoneConfig     `json:"zones"`
+	Upstreams []UpstreamConfig `json:"upstreams"`
+	Routes    []RouteConfig    `json:"routes"`
+}
+
+// loadFile reads structured settings from a JSON config file into config
+func loadFile(path string, config *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var file fileConfig
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	config.Zones = file.Zones
+	config.Upstreams = file.Upstreams
+	config.Routes = file.Routes
+	return nil
+}
@@ -15,6 +15,7 @@ const (
 	ErrorTypeInternal     ErrorType = "internal"
 	ErrorTypeNotFound     ErrorType = "not_found"
 	ErrorTypeTimeout      ErrorType = "timeout"
+	ErrorTypeForbidden    ErrorType = "forbidden"
 )
 
 // AppError represents a structured application error
@@ -90,10 +91,24 @@ var (
 		http.StatusInternalServerError,
 	)
 
+	ErrAdminForbidden = New(
+		ErrorTypeForbidden,
+		"ADMIN_FORBIDDEN",
+		"Client is not allowed to perform cache administration",
+		http.StatusForbidden,
+	)
+
 	ErrRequestCreation = New(
 		ErrorTypeInternal,
 		"REQUEST_CREATION_FAILED",
 		"Failed to create request to origin server",
 		http.StatusInternalServerError,
 	)
+
+	ErrCircuitOpen = New(
+		ErrorTypeNetwork,
+		"CIRCUIT_OPEN",
+		"Origin server is failing and its circuit breaker is open",
+		http.StatusServiceUnavailable,
+	)
 )
@@ -3,7 +3,11 @@ package health
 import (
 	"cache-proxy/internal/cache"
 	"cache-proxy/internal/logger"
+	"fmt"
 	"net/http"
+	"runtime"
+	"runtime/metrics"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +15,13 @@ import (
 
 // HealthCheck represents the health status of the application
 type HealthCheck struct {
-	Status    string            `json:"status"`
-	Timestamp time.Time         `json:"timestamp"`
-	Version   string            `json:"version"`
-	Uptime    time.Duration     `json:"uptime"`
-	Cache     CacheHealthStatus `json:"cache"`
-	System    SystemHealthStatus `json:"system"`
+	Status     string                     `json:"status"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Version    string                     `json:"version"`
+	Uptime     time.Duration              `json:"uptime"`
+	Cache      CacheHealthStatus          `json:"cache"`
+	System     SystemHealthStatus         `json:"system"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
 }
 
 // CacheHealthStatus represents cache health information
@@ -27,39 +32,60 @@ type CacheHealthStatus struct {
 
 // SystemHealthStatus represents system health information
 type SystemHealthStatus struct {
-	Status       string `json:"status"`
-	MemoryUsage  string `json:"memory_usage"`
-	Goroutines   int    `json:"goroutines"`
+	Status      string `json:"status"`
+	MemoryUsage string `json:"memory_usage"`
+	Goroutines  int    `json:"goroutines"`
 }
 
+// ComponentStatus represents the health of an optional subsystem
+type ComponentStatus struct {
+	Status  string      `json:"status"` // healthy, degraded or unhealthy
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ComponentCheck reports the current status of a component
+type ComponentCheck func() ComponentStatus
+
 // Service provides health check functionality
 type Service struct {
 	cache     cache.Cache
 	logger    logger.Logger
 	startTime time.Time
 	version   string
+
+	componentsMutex sync.RWMutex
+	components      map[string]ComponentCheck
 }
 
 // NewService creates a new health check service
 func NewService(cache cache.Cache, logger logger.Logger, version string) *Service {
 	return &Service{
-		cache:     cache,
-		logger:    logger,
-		startTime: time.Now(),
-		version:   version,
+		cache:      cache,
+		logger:     logger,
+		startTime:  time.Now(),
+		version:    version,
+		components: make(map[string]ComponentCheck),
 	}
 }
 
+// AddComponent registers a subsystem whose status is included in health checks.
+// A degraded component degrades overall health without failing it.
+func (s *Service) AddComponent(name string, check ComponentCheck) {
+	s.componentsMutex.Lock()
+	defer s.componentsMutex.Unlock()
+	s.components[name] = check
+}
+
 // HandleHealthCheck returns a health check handler
 func (s *Service) HandleHealthCheck() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		health := s.GetHealthStatus()
-		
+
 		status := http.StatusOK
-		if health.Status != "healthy" {
+		if health.Status == "unhealthy" {
 			status = http.StatusServiceUnavailable
 		}
-		
+
 		c.JSON(status, health)
 	}
 }
@@ -75,9 +101,9 @@ func (s *Service) HandleReadiness() gin.HandlerFunc {
 			})
 			return
 		}
-		
+
 		c.JSON(http.StatusOK, gin.H{
-			"status": "ready",
+			"status":    "ready",
 			"timestamp": time.Now(),
 		})
 	}
@@ -87,9 +113,9 @@ func (s *Service) HandleReadiness() gin.HandlerFunc {
 func (s *Service) HandleLiveness() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"status": "alive",
+			"status":    "alive",
 			"timestamp": time.Now(),
-			"uptime": time.Since(s.startTime),
+			"uptime":    time.Since(s.startTime),
 		})
 	}
 }
@@ -100,19 +126,29 @@ func (s *Service) GetHealthStatus() HealthCheck {
 	if s.cache == nil {
 		cacheStatus = "unhealthy"
 	}
-	
+
 	systemStatus := "healthy"
-	
+
 	overallStatus := "healthy"
 	if cacheStatus != "healthy" || systemStatus != "healthy" {
 		overallStatus = "unhealthy"
 	}
-	
+
+	components := s.componentStatuses()
+	for _, component := range components {
+		switch {
+		case component.Status == "unhealthy":
+			overallStatus = "unhealthy"
+		case component.Status == "degraded" && overallStatus == "healthy":
+			overallStatus = "degraded"
+		}
+	}
+
 	var cacheStats cache.Stats
 	if s.cache != nil {
 		cacheStats = s.cache.Stats()
 	}
-	
+
 	return HealthCheck{
 		Status:    overallStatus,
 		Timestamp: time.Now(),
@@ -123,9 +159,36 @@ func (s *Service) GetHealthStatus() HealthCheck {
 			Stats:  cacheStats,
 		},
 		System: SystemHealthStatus{
-			Status: systemStatus,
-			MemoryUsage: "N/A", // Could implement actual memory monitoring
-			Goroutines: 0,      // Could implement actual goroutine counting
+			Status:      systemStatus,
+			MemoryUsage: heapUsage(),
+			Goroutines:  runtime.NumGoroutine(),
 		},
+		Components: components,
+	}
+}
+
+// componentStatuses runs every registered component check
+func (s *Service) componentStatuses() map[string]ComponentStatus {
+	s.componentsMutex.RLock()
+	defer s.componentsMutex.RUnlock()
+
+	if len(s.components) == 0 {
+		return nil
+	}
+
+	statuses := make(map[string]ComponentStatus, len(s.components))
+	for name, check := range s.components {
+		statuses[name] = check()
+	}
+	return statuses
+}
+
+// heapUsage returns live heap object bytes in human-readable form
+func heapUsage() string {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return "N/A"
 	}
+	return fmt.Sprintf("%.1f MiB", float64(sample[0].Value.Uint64())/(1<<20))
 }
@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/logger"
+)
+
+const (
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+	totalMemoryMetric = "/memory/classes/total:bytes"
+
+	// maxGCBackoff caps the wait between forced collections while pressure lasts
+	maxGCBackoff = time.Minute
+)
+
+// Config controls when the monitor considers the process under memory pressure
+type Config struct {
+	SoftLimit int64         `json:"soft_limit"` // heap bytes that trigger eviction
+	LowWater  float64       `json:"low_water"`  // fraction of SoftLimit to evict down to
+	Interval  time.Duration `json:"interval"`
+}
+
+// Stats reports heap usage and pressure events
+type Stats struct {
+	SoftLimit       int64      `json:"soft_limit"`
+	LowWaterBytes   int64      `json:"low_water_bytes"`
+	HeapBytes       int64      `json:"heap_bytes"`
+	TotalBytes      int64      `json:"total_bytes"`
+	UnderPressure   bool       `json:"under_pressure"`
+	PressureEvents  int64      `json:"pressure_events"`
+	EvictedEntries  int64      `json:"evicted_entries"`
+	SkippedReliefs  int64      `json:"skipped_reliefs"`
+	ForcedGCs       int64      `json:"forced_gcs"`
+	LastPressureAt  *time.Time `json:"last_pressure_at,omitempty"`
+	LastCheckedAt   time.Time  `json:"last_checked_at"`
+	GoMemoryLimit   int64      `json:"go_memory_limit"`
+	CachesMonitored int        `json:"caches_monitored"`
+}
+
+// Monitor samples heap usage via runtime/metrics and evicts cache entries
+// down to a low-water mark whenever the soft limit is exceeded
+type Monitor struct {
+	config        Config
+	caches        []cache.Cache
+	logger        logger.Logger
+	samples       []metrics.Sample
+	previousLimit int64
+	started       bool
+	stop          chan struct{}
+	done          chan struct{}
+
+	// Only used by the sampling goroutine. relievedTo is what the caches
+	// held after the last relief; while they hold no more, pressure comes
+	// from elsewhere and evicting again would only empty them.
+	relievedTo int64
+	nextGC     time.Time
+	gcBackoff  time.Duration
+
+	mutex sync.Mutex
+	stats Stats
+}
+
+// NewMonitor creates a monitor for the given caches
+func NewMonitor(config Config, log logger.Logger, caches ...cache.Cache) *Monitor {
+	if config.LowWater <= 0 || config.LowWater >= 1 {
+		config.LowWater = 0.8
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+
+	return &Monitor{
+		config:     config,
+		caches:     caches,
+		logger:     log,
+		relievedTo: -1,
+		samples: []metrics.Sample{
+			{Name: heapObjectsMetric},
+			{Name: totalMemoryMetric},
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		stats: Stats{
+			SoftLimit:       config.SoftLimit,
+			LowWaterBytes:   int64(float64(config.SoftLimit) * config.LowWater),
+			CachesMonitored: len(caches),
+		},
+	}
+}
+
+// Start sets the Go runtime memory limit to the soft limit so the GC works
+// harder as it approaches, and begins sampling in the background
+func (m *Monitor) Start() {
+	m.previousLimit = debug.SetMemoryLimit(m.config.SoftLimit)
+
+	m.mutex.Lock()
+	m.started = true
+	m.stats.GoMemoryLimit = m.config.SoftLimit
+	m.mutex.Unlock()
+
+	m.logger.Info().
+		Int64("soft_limit", m.config.SoftLimit).
+		Int64("low_water", m.stats.LowWaterBytes).
+		Dur("interval", m.config.Interval).
+		Msg("Memory pressure monitor started")
+
+	go m.run()
+}
+
+// Stop halts sampling and restores the previous runtime memory limit
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	started := m.started
+	m.mutex.Unlock()
+	if !started {
+		return
+	}
+
+	close(m.stop)
+	<-m.done
+	debug.SetMemoryLimit(m.previousLimit)
+}
+
+// Stats returns a snapshot of the monitor's state
+func (m *Monitor) Stats() Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
+}
+
+// run checks heap usage on every tick until stopped
+func (m *Monitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// check samples the heap and relieves pressure if it is over the soft limit
+func (m *Monitor) check() {
+	heapBytes, totalBytes := m.read()
+
+	m.mutex.Lock()
+	m.stats.HeapBytes = heapBytes
+	m.stats.TotalBytes = totalBytes
+	m.stats.LastCheckedAt = time.Now()
+	m.stats.UnderPressure = heapBytes > m.config.SoftLimit
+	underPressure := m.stats.UnderPressure
+	m.mutex.Unlock()
+
+	if !underPressure {
+		m.relievedTo = -1
+		m.gcBackoff = 0
+		return
+	}
+
+	now := time.Now()
+	if cached := m.cachedBytes(); cached <= m.relievedTo {
+		m.mutex.Lock()
+		m.stats.SkippedReliefs++
+		m.mutex.Unlock()
+		m.logger.Debug().
+			Int64("heap_bytes", heapBytes).
+			Int64("cached_bytes", cached).
+			Msg("Memory pressure persists without cache growth, not evicting again")
+		return
+	}
+
+	evicted := m.relieve(heapBytes - m.stats.LowWaterBytes)
+	m.relievedTo = m.cachedBytes()
+
+	// Return the freed bodies to the heap right away rather than waiting
+	// for the next cycle, backing off while pressure lasts so a heap that
+	// stays over the limit doesn't collect on every tick
+	forced := false
+	if !now.Before(m.nextGC) {
+		runtime.GC()
+		forced = true
+		if m.gcBackoff = 2 * m.gcBackoff; m.gcBackoff < m.config.Interval {
+			m.gcBackoff = m.config.Interval
+		} else if m.gcBackoff > maxGCBackoff {
+			m.gcBackoff = maxGCBackoff
+		}
+		m.nextGC = now.Add(m.gcBackoff)
+	}
+
+	m.mutex.Lock()
+	m.stats.PressureEvents++
+	m.stats.EvictedEntries += int64(evicted)
+	m.stats.LastPressureAt = &now
+	if forced {
+		m.stats.ForcedGCs++
+	}
+	m.mutex.Unlock()
+
+	m.logger.Warn().
+		Int64("heap_bytes", heapBytes).
+		Int64("soft_limit", m.config.SoftLimit).
+		Int("evicted", evicted).
+		Bool("forced_gc", forced).
+		Msg("Memory pressure detected, evicted cache entries")
+}
+
+// cachedBytes returns the bytes held by all caches
+func (m *Monitor) cachedBytes() int64 {
+	var total int64
+	for _, c := range m.caches {
+		total += c.Stats().Bytes
+	}
+	return total
+}
+
+// relieve evicts roughly excess bytes across all caches, in proportion to
+// how much each one holds
+func (m *Monitor) relieve(excess int64) int {
+	var total int64
+	sizes := make([]int64, len(m.caches))
+	for i, c := range m.caches {
+		sizes[i] = c.Stats().Bytes
+		total += sizes[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	evicted := 0
+	for i, c := range m.caches {
+		share := int64(math.Ceil(float64(excess) * float64(sizes[i]) / float64(total)))
+		target := sizes[i] - share
+		if target < 0 {
+			target = 0
+		}
+		evicted += c.Shrink(target)
+	}
+	return evicted
+}
+
+// read returns live heap object bytes and total memory mapped by the runtime
+func (m *Monitor) read() (int64, int64) {
+	metrics.Read(m.samples)
+
+	var heapBytes, totalBytes int64
+	if m.samples[0].Value.Kind() == metrics.KindUint64 {
+		heapBytes = int64(m.samples[0].Value.Uint64())
+	}
+	if m.samples[1].Value.Kind() == metrics.KindUint64 {
+		totalBytes = int64(m.samples[1].Value.Uint64())
+	}
+	return heapBytes, totalBytes
+}
@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cache-proxy/internal/logger"
+)
+
+const (
+	// adaptiveBuckets is how many slices the rolling window is divided into
+	adaptiveBuckets = 10
+	// adaptiveMinSamples is how many origin requests the window needs before
+	// its latency and error rate are trusted
+	adaptiveMinSamples = 20
+	// adaptiveRecovery is the fraction of each threshold the origin must
+	// drop below before TTLs return to normal, so the mode doesn't flap
+	adaptiveRecovery = 0.8
+)
+
+// AdaptiveTTLStats reports origin health over the rolling window and
+// whether TTLs are currently stretched
+type AdaptiveTTLStats struct {
+	Enabled          bool          `json:"enabled"`
+	Active           bool          `json:"active"`
+	Multiplier       float64       `json:"multiplier"`
+	MaxTTL           time.Duration `json:"max_ttl"`
+	Requests         int64         `json:"requests"`
+	Failures         int64         `json:"failures"`
+	ErrorRate        float64       `json:"error_rate"`
+	MeanLatency      time.Duration `json:"mean_latency"`
+	LatencyThreshold time.Duration `json:"latency_threshold"`
+	ErrorThreshold   float64       `json:"error_threshold"`
+	Activations      int64         `json:"activations"`
+	ActiveSince      *time.Time    `json:"active_since,omitempty"`
+}
+
+// originBucket aggregates origin requests for one slice of the window
+type originBucket struct {
+	start    time.Time
+	requests int64
+	failures int64
+	latency  time.Duration
+}
+
+// adaptiveTTL tracks origin latency and error rate over a rolling window
+// and stretches TTLs while either is over its threshold, shedding load from
+// a struggling origin
+type adaptiveTTL struct {
+	latencyThreshold time.Duration
+	errorThreshold   float64
+	factor           float64
+	maxTTL           time.Duration
+	bucketWidth      time.Duration
+	logger           logger.Logger
+
+	mutex       sync.Mutex
+	buckets     [adaptiveBuckets]originBucket
+	active      bool
+	activeSince *time.Time
+	activations int64
+
+	// The mode and the time of the last origin request, published for
+	// extend, which runs on every cache lookup and must not take the mutex
+	published    atomic.Bool
+	lastObserved atomic.Int64
+}
+
+// newAdaptiveTTL creates a tracker; with no thresholds it never activates
+func newAdaptiveTTL(latency time.Duration, errorRate, factor float64, maxTTL, window time.Duration, log logger.Logger) *adaptiveTTL {
+	bucketWidth := window / adaptiveBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = time.Millisecond
+	}
+	return &adaptiveTTL{
+		latencyThreshold: latency,
+		errorThreshold:   errorRate,
+		factor:           factor,
+		maxTTL:           maxTTL,
+		bucketWidth:      bucketWidth,
+		logger:           log,
+	}
+}
+
+// enabled reports whether any threshold is configured
+func (a *adaptiveTTL) enabled() bool {
+	return a.factor > 1 && (a.latencyThreshold > 0 || a.errorThreshold > 0)
+}
+
+// observe records one origin request and re-evaluates the mode
+func (a *adaptiveTTL) observe(latency time.Duration, failed bool) {
+	if !a.enabled() {
+		return
+	}
+
+	now := time.Now()
+	start := now.Truncate(a.bucketWidth)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	bucket := &a.buckets[(start.UnixNano()/int64(a.bucketWidth))%adaptiveBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = originBucket{start: start}
+	}
+	bucket.requests++
+	bucket.latency += latency
+	if failed {
+		bucket.failures++
+	}
+
+	a.evaluate(now)
+	a.published.Store(a.active)
+	a.lastObserved.Store(now.UnixNano())
+}
+
+// extend returns ttl stretched by the factor, capped at the max TTL, while
+// adaptive mode is active. TTLs that never expire are left alone.
+func (a *adaptiveTTL) extend(ttl time.Duration) time.Duration {
+	if ttl <= 0 || !a.enabled() || !a.stretching() {
+		return ttl
+	}
+	extended := time.Duration(float64(ttl) * a.factor)
+	if a.maxTTL > 0 && extended > a.maxTTL {
+		extended = a.maxTTL
+	}
+	if extended < ttl {
+		return ttl
+	}
+	return extended
+}
+
+// stretching reports whether adaptive mode is active as last published. A
+// window without any origin request means the origin has recovered, as in
+// evaluate, even before the next request or stats call notices.
+func (a *adaptiveTTL) stretching() bool {
+	if !a.published.Load() {
+		return false
+	}
+	return time.Since(time.Unix(0, a.lastObserved.Load())) < a.bucketWidth*adaptiveBuckets
+}
+
+// stats returns a snapshot of the window and mode
+func (a *adaptiveTTL) stats() AdaptiveTTLStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.enabled() {
+		a.evaluate(time.Now())
+		a.published.Store(a.active)
+	}
+	requests, failures, latency := a.totals(time.Now())
+
+	stats := AdaptiveTTLStats{
+		Enabled:          a.enabled(),
+		Active:           a.active,
+		Multiplier:       1,
+		MaxTTL:           a.maxTTL,
+		Requests:         requests,
+		Failures:         failures,
+		LatencyThreshold: a.latencyThreshold,
+		ErrorThreshold:   a.errorThreshold,
+		Activations:      a.activations,
+		ActiveSince:      a.activeSince,
+	}
+	if a.active {
+		stats.Multiplier = a.factor
+	}
+	if requests > 0 {
+		stats.ErrorRate = float64(failures) / float64(requests)
+		stats.MeanLatency = latency / time.Duration(requests)
+	}
+	return stats
+}
+
+// totals sums the buckets still inside the window. Must be called with the
+// mutex held.
+func (a *adaptiveTTL) totals(now time.Time) (int64, int64, time.Duration) {
+	cutoff := now.Add(-a.bucketWidth * adaptiveBuckets)
+
+	var requests, failures int64
+	var latency time.Duration
+	for _, bucket := range a.buckets {
+		if bucket.start.After(cutoff) {
+			requests += bucket.requests
+			failures += bucket.failures
+			latency += bucket.latency
+		}
+	}
+	return requests, failures, latency
+}
+
+// evaluate enters adaptive mode when a threshold is crossed and leaves it
+// once both metrics recover, or the window empties because every request
+// is being served from cache. Must be called with the mutex held.
+func (a *adaptiveTTL) evaluate(now time.Time) {
+	requests, failures, latency := a.totals(now)
+
+	var errorRate float64
+	var meanLatency time.Duration
+	if requests > 0 {
+		errorRate = float64(failures) / float64(requests)
+		meanLatency = latency / time.Duration(requests)
+	}
+
+	if !a.active {
+		if requests < adaptiveMinSamples {
+			return
+		}
+		slow := a.latencyThreshold > 0 && meanLatency > a.latencyThreshold
+		failing := a.errorThreshold > 0 && errorRate > a.errorThreshold
+		if !slow && !failing {
+			return
+		}
+
+		a.active = true
+		a.activeSince = &now
+		a.activations++
+		a.logger.Warn().
+			Dur("mean_latency", meanLatency).
+			Float64("error_rate", errorRate).
+			Int64("requests", requests).
+			Float64("multiplier", a.factor).
+			Msg("Origin degraded, adaptive TTL extension active")
+		return
+	}
+
+	slow := a.latencyThreshold > 0 && meanLatency > time.Duration(float64(a.latencyThreshold)*adaptiveRecovery)
+	failing := a.errorThreshold > 0 && errorRate > a.errorThreshold*adaptiveRecovery
+	if requests > 0 && (slow || failing) {
+		return
+	}
+
+	a.active = false
+	a.activeSince = nil
+	a.logger.Info().
+		Dur("mean_latency", meanLatency).
+		Float64("error_rate", errorRate).
+		Int64("requests", requests).
+		Msg("Origin recovered, adaptive TTL extension ended")
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"cache-proxy/internal/logger"
+
+	"github.com/rs/zerolog"
+)
+
+// TestAdaptiveTTLExtend checks that TTLs are stretched, capped, while the
+// origin is failing and return to normal once it recovers
+func TestAdaptiveTTLExtend(t *testing.T) {
+	a := newAdaptiveTTL(0, 0.5, 4, 3*time.Minute, time.Minute, logger.NewWithLevel(zerolog.Disabled))
+
+	if got := a.extend(time.Minute); got != time.Minute {
+		t.Fatalf("healthy origin: extend = %v, want 1m", got)
+	}
+
+	for i := 0; i < adaptiveMinSamples; i++ {
+		a.observe(time.Millisecond, true)
+	}
+	if got := a.extend(time.Second); got != 4*time.Second {
+		t.Errorf("failing origin: extend(1s) = %v, want 4s", got)
+	}
+	if got := a.extend(time.Minute); got != 3*time.Minute {
+		t.Errorf("failing origin: extend(1m) = %v, want the 3m cap", got)
+	}
+	if got := a.extend(0); got != 0 {
+		t.Errorf("extend(0) = %v, want 0", got)
+	}
+
+	for i := 0; i < 10*adaptiveMinSamples; i++ {
+		a.observe(time.Millisecond, false)
+	}
+	if got := a.extend(time.Second); got != time.Second {
+		t.Errorf("recovered origin: extend(1s) = %v, want 1s", got)
+	}
+}
@@ -0,0 +1,355 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// handleListEntries returns a filtered, paginated listing of cached entries
+func (s *Server) handleListEntries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, appErr := parseListFilter(c)
+		if appErr != nil {
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		zones := s.zones
+		if name := c.Query("zone"); name != "" {
+			z, exists := s.zoneByName(name)
+			if !exists {
+				appErr := errors.New(errors.ErrorTypeNotFound, "ZONE_NOT_FOUND", "No cache zone with that name", http.StatusNotFound)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			zones = []*zone{z}
+		}
+
+		entries, total := listZones(zones, filter)
+		c.JSON(http.StatusOK, gin.H{
+			"entries":   entries,
+			"total":     total,
+			"offset":    filter.Offset,
+			"limit":     filter.Limit,
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// handleGetEntry returns one entry's metadata, headers and body. With
+// ?raw=true the stored body is written as-is for debugging.
+func (s *Server) handleGetEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		z, entry, exists := s.findEntry(key)
+		if !exists {
+			appErr := errors.New(errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached entry for key", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		if raw, _ := strconv.ParseBool(c.Query("raw")); raw {
+			c.Data(http.StatusOK, entry.Headers.Get("Content-Type"), entry.Body)
+			return
+		}
+
+		info := entry.Info(key)
+		info.Zone = z.name
+		c.JSON(http.StatusOK, gin.H{
+			"entry":   info,
+			"headers": entry.Headers,
+			"body":    entry.Body,
+		})
+	}
+}
+
+// pushEntryRequest is the body accepted by PUT /cache/entries
+type pushEntryRequest struct {
+	URL        string      `json:"url" binding:"required"`
+	Host       string      `json:"host"` // required unless url is absolute
+	Method     string      `json:"method"`
+	Status     int         `json:"status"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+	BodyBase64 string      `json:"body_base64"`
+	TTL        string      `json:"ttl"` // Go duration; "0" never expires, empty uses the zone TTL
+	Pinned     bool        `json:"pinned"`
+	Zone       string      `json:"zone"`  // empty routes by URL like a client request
+	Route      string      `json:"route"` // empty routes by URL like a client request
+}
+
+// handlePushEntry stores a caller-supplied response for a URL, optionally pinned
+func (s *Server) handlePushEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req pushEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ENTRY", "Request body must be JSON with a url field", http.StatusBadRequest)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		target, appErr := parseEntryURL(req.URL, req.Host, "INVALID_ENTRY")
+		if appErr != nil {
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		rt, ok := s.routeFor(target.Host, target.Path)
+		if req.Route != "" {
+			rt, ok = s.routeByName(req.Route)
+		}
+		if !ok {
+			appErr := errors.New(errors.ErrorTypeNotFound, "ROUTE_NOT_FOUND", "No route matches the entry URL", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		z := s.zoneForRoute(rt, target.Host, target.Path)
+		if req.Zone != "" {
+			var exists bool
+			if z, exists = s.zoneByName(req.Zone); !exists {
+				appErr := errors.New(errors.ErrorTypeNotFound, "ZONE_NOT_FOUND", "No cache zone with that name", http.StatusNotFound)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+		}
+
+		entry, appErr := entryFromPush(req, target, policyFor(z, rt).SuccessTTL)
+		if appErr != nil {
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		entry.Route = rt.name
+
+		cacheKey := z.cache.GenerateKey(rt.name, entry.Method, target.Host, target.Path, target.RawQuery)
+		if err := z.cache.Set(cacheKey, entry); err == cache.ErrFrozen {
+			appErr := errors.Wrap(err, errors.ErrorTypeCacheFailure, "ENTRY_FROZEN", "Cached entry is frozen; unfreeze it before replacing it", http.StatusConflict)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		} else if err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeCacheFailure, "CACHE_SET_FAILED", "Failed to store entry in cache", http.StatusInsufficientStorage)
+			s.logger.Error().Err(appErr).Str("zone", z.name).Str("cache_key", cacheKey).Msg("Failed to push cache entry")
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		s.logger.Info().
+			Str("url", entry.URL).
+			Str("zone", z.name).
+			Str("cache_key", cacheKey).
+			Bool("pinned", entry.Pinned).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Cache entry pushed via API")
+
+		info := entry.Info(cacheKey)
+		info.Zone = z.name
+		c.JSON(http.StatusCreated, gin.H{"entry": info})
+	}
+}
+
+// parseEntryURL parses the URL of a cache entry named through the admin API.
+// Entries are keyed by host like client requests, so the URL must be
+// absolute or host must be given; code is reported on errors.
+func parseEntryURL(rawURL, host, code string) (*url.URL, *errors.AppError) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Path == "" {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, code, "url must be an absolute path or URL", http.StatusBadRequest)
+	}
+
+	switch {
+	case target.Host == "" && host == "":
+		return nil, errors.New(errors.ErrorTypeValidation, code, "url must be absolute or a host must be given", http.StatusBadRequest)
+	case target.Host == "":
+		target.Host = host
+	case host != "" && !strings.EqualFold(host, target.Host):
+		return nil, errors.New(errors.ErrorTypeValidation, code, "host does not match the url's host", http.StatusBadRequest)
+	}
+	return target, nil
+}
+
+// entryFromPush validates a push request for target and converts it to a
+// cache entry, using defaultTTL when the request doesn't set one
+func entryFromPush(req pushEntryRequest, target *url.URL, defaultTTL time.Duration) (*cache.Entry, *errors.AppError) {
+	var err error
+	entry := &cache.Entry{
+		URL:     target.RequestURI(),
+		Host:    target.Host,
+		Method:  strings.ToUpper(req.Method),
+		Status:  req.Status,
+		Headers: req.Headers,
+		Body:    []byte(req.Body),
+		TTL:     defaultTTL,
+		Pinned:  req.Pinned,
+	}
+	if entry.Method == "" {
+		entry.Method = http.MethodGet
+	}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if entry.Headers == nil {
+		entry.Headers = make(http.Header)
+	}
+
+	if req.BodyBase64 != "" {
+		if entry.Body, err = base64.StdEncoding.DecodeString(req.BodyBase64); err != nil {
+			return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ENTRY", "body_base64 is not valid base64", http.StatusBadRequest)
+		}
+	}
+
+	if req.TTL != "" {
+		if entry.TTL, err = time.ParseDuration(req.TTL); err != nil || entry.TTL < 0 {
+			return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ENTRY", "ttl must be a non-negative duration", http.StatusBadRequest)
+		}
+	}
+
+	return entry, nil
+}
+
+// handlePinEntry pins or unpins the entry stored under :key
+func (s *Server) handlePinEntry(pinned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		z, _, exists := s.findEntry(key)
+		if !exists {
+			appErr := errors.New(errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached entry for key", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		if err := z.cache.Pin(key, pinned); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached entry for key", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		s.logger.Info().
+			Str("zone", z.name).
+			Str("cache_key", key).
+			Bool("pinned", pinned).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Cache entry pin updated")
+
+		c.JSON(http.StatusOK, gin.H{
+			"key":       key,
+			"pinned":    pinned,
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// listZones lists matching entries across zones, sorted and paginated as a
+// single listing
+func listZones(zones []*zone, filter cache.ListFilter) ([]cache.EntryInfo, int) {
+	if len(zones) == 1 {
+		entries, total := zones[0].cache.List(filter)
+		for i := range entries {
+			entries[i].Zone = zones[0].name
+		}
+		return entries, total
+	}
+
+	all := filter
+	all.Offset, all.Limit = 0, 0
+
+	var matches []cache.EntryInfo
+	for _, z := range zones {
+		entries, _ := z.cache.List(all)
+		for i := range entries {
+			entries[i].Zone = z.name
+		}
+		matches = append(matches, entries...)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].URL != matches[j].URL {
+			return matches[i].URL < matches[j].URL
+		}
+		return matches[i].Zone < matches[j].Zone
+	})
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return []cache.EntryInfo{}, total
+	}
+	matches = matches[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matches) {
+		matches = matches[:filter.Limit]
+	}
+	return matches, total
+}
+
+// parseListFilter builds a cache.ListFilter from query parameters
+func parseListFilter(c *gin.Context) (cache.ListFilter, *errors.AppError) {
+	filter := cache.ListFilter{
+		PathPrefix:  c.Query("prefix"),
+		Status:      c.Query("status"),
+		ContentType: c.Query("content_type"),
+		Limit:       defaultListLimit,
+	}
+
+	var err *errors.AppError
+	if filter.MinAge, err = queryDuration(c, "min_age"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAge, err = queryDuration(c, "max_age"); err != nil {
+		return filter, err
+	}
+	if filter.MinSize, err = queryInt(c, "min_size", 0); err != nil {
+		return filter, err
+	}
+	if filter.MaxSize, err = queryInt(c, "max_size", 0); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = queryInt(c, "offset", 0); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = queryInt(c, "limit", defaultListLimit); err != nil {
+		return filter, err
+	}
+	if filter.Limit <= 0 || filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	return filter, nil
+}
+
+// queryInt parses a non-negative integer query parameter
+func queryInt(c *gin.Context, name string, defaultValue int) (int, *errors.AppError) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, errors.New(errors.ErrorTypeValidation, "INVALID_QUERY", name+" must be a non-negative integer", http.StatusBadRequest)
+	}
+	return parsed, nil
+}
+
+// queryDuration parses a duration query parameter such as "30s" or "2h"
+func queryDuration(c *gin.Context, name string) (time.Duration, *errors.AppError) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, errors.New(errors.ErrorTypeValidation, "INVALID_QUERY", name+" must be a non-negative duration", http.StatusBadRequest)
+	}
+	return parsed, nil
+}
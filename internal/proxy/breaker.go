@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"cache-proxy/internal/health"
+	"cache-proxy/internal/logger"
+)
+
+// breakerBuckets is how many slices a circuit breaker's window is divided into
+const breakerBuckets = 10
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// BreakerStats reports a circuit breaker's state and the window it trips on
+type BreakerStats struct {
+	Enabled          bool          `json:"enabled"`
+	State            string        `json:"state"`
+	Requests         int64         `json:"requests"`
+	Failures         int64         `json:"failures"`
+	ErrorRate        float64       `json:"error_rate"`
+	MeanLatency      time.Duration `json:"mean_latency"`
+	ErrorThreshold   float64       `json:"error_threshold"`
+	LatencyThreshold time.Duration `json:"latency_threshold"`
+	Opens            int64         `json:"opens"`
+	Rejected         int64         `json:"rejected"`
+	StaleServed      int64         `json:"stale_served"`
+	OpenedAt         *time.Time    `json:"opened_at,omitempty"`
+	RetryAt          *time.Time    `json:"retry_at,omitempty"`
+}
+
+// circuitBreaker stops sending requests to an upstream whose error rate or
+// latency over a rolling window crosses a threshold. After the cool-down it
+// lets a few trial requests through and closes again once they all succeed.
+type circuitBreaker struct {
+	name             string
+	errorThreshold   float64
+	latencyThreshold time.Duration
+	minRequests      int64
+	cooldown         time.Duration
+	trialRequests    int
+	bucketWidth      time.Duration
+	logger           logger.Logger
+
+	mutex       sync.Mutex
+	buckets     [breakerBuckets]originBucket
+	state       string
+	openedAt    time.Time
+	trials      int // trial requests let through while half-open
+	passed      int // trial requests that succeeded
+	opens       int64
+	rejected    int64
+	staleServed int64
+}
+
+// newCircuitBreaker creates a closed breaker for the named upstream; with no
+// thresholds it never opens
+func newCircuitBreaker(name string, errorRate float64, latency time.Duration, minRequests int, window, cooldown time.Duration, trialRequests int, log logger.Logger) *circuitBreaker {
+	bucketWidth := window / breakerBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = time.Millisecond
+	}
+	if trialRequests <= 0 {
+		trialRequests = 1
+	}
+	return &circuitBreaker{
+		name:             name,
+		errorThreshold:   errorRate,
+		latencyThreshold: latency,
+		minRequests:      int64(minRequests),
+		cooldown:         cooldown,
+		trialRequests:    trialRequests,
+		bucketWidth:      bucketWidth,
+		logger:           log,
+		state:            breakerClosed,
+	}
+}
+
+// enabled reports whether any threshold is configured
+func (b *circuitBreaker) enabled() bool {
+	return b.errorThreshold > 0 || b.latencyThreshold > 0
+}
+
+// breakerTicket is a request the breaker let through. Exactly one of its
+// methods must be called when the request ends.
+type breakerTicket struct {
+	breaker *circuitBreaker
+	trial   bool
+	opens   int64 // the breaker's opens when a trial was let through
+}
+
+// done records the request's outcome
+func (t breakerTicket) done(latency time.Duration, failed bool) {
+	switch {
+	case t.breaker == nil:
+	case t.trial:
+		t.breaker.recordTrial(latency, failed)
+	default:
+		t.breaker.record(latency, failed)
+	}
+}
+
+// cancel ends a request cancelled by the client or a winning hedge, which
+// says nothing about the upstream: a trial's slot is handed back for
+// another trial and nothing is recorded
+func (t breakerTicket) cancel() {
+	if t.breaker == nil || !t.trial {
+		return
+	}
+
+	b := t.breaker
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == breakerHalfOpen && b.opens == t.opens && b.trials > 0 {
+		b.trials--
+	}
+}
+
+// acquire asks to send a request upstream. It returns a ticket to end the
+// request with, or false when the breaker is open.
+func (b *circuitBreaker) acquire() (breakerTicket, bool) {
+	if !b.enabled() {
+		return breakerTicket{}, true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	if b.state == breakerOpen && now.Sub(b.openedAt) >= b.cooldown {
+		b.state = breakerHalfOpen
+		b.trials, b.passed = 0, 0
+		b.logger.Info().Str("upstream", b.name).Msg("Circuit breaker half-open, sending trial requests")
+	}
+
+	switch b.state {
+	case breakerOpen:
+		b.rejected++
+		return breakerTicket{}, false
+	case breakerHalfOpen:
+		if b.trials >= b.trialRequests {
+			b.rejected++
+			return breakerTicket{}, false
+		}
+		b.trials++
+		return breakerTicket{breaker: b, trial: true, opens: b.opens}, true
+	default:
+		return breakerTicket{breaker: b}, true
+	}
+}
+
+// servedStale counts a response served from stale cache while open
+func (b *circuitBreaker) servedStale() {
+	b.mutex.Lock()
+	b.staleServed++
+	b.mutex.Unlock()
+}
+
+// record adds a request made while closed to the window and opens the
+// breaker if a threshold is crossed
+func (b *circuitBreaker) record(latency time.Duration, failed bool) {
+	now := time.Now()
+	start := now.Truncate(b.bucketWidth)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	bucket := &b.buckets[(start.UnixNano()/int64(b.bucketWidth))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = originBucket{start: start}
+	}
+	bucket.requests++
+	bucket.latency += latency
+	if failed {
+		bucket.failures++
+	}
+
+	// A request that started before the breaker opened may finish after it
+	if b.state != breakerClosed {
+		return
+	}
+
+	requests, failures, total := b.totals(now)
+	if requests < b.minRequests {
+		return
+	}
+	errorRate := float64(failures) / float64(requests)
+	meanLatency := total / time.Duration(requests)
+	failing := b.errorThreshold > 0 && errorRate >= b.errorThreshold
+	slow := b.latencyThreshold > 0 && meanLatency >= b.latencyThreshold
+	if !failing && !slow {
+		return
+	}
+
+	b.open(now)
+	b.logger.Warn().
+		Str("upstream", b.name).
+		Float64("error_rate", errorRate).
+		Dur("mean_latency", meanLatency).
+		Int64("requests", requests).
+		Dur("cooldown", b.cooldown).
+		Msg("Circuit breaker opened")
+}
+
+// recordTrial settles a half-open trial: one failure or slow response
+// reopens the breaker, enough successes close it
+func (b *circuitBreaker) recordTrial(latency time.Duration, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state != breakerHalfOpen {
+		return
+	}
+	if failed || (b.latencyThreshold > 0 && latency >= b.latencyThreshold) {
+		b.open(time.Now())
+		b.logger.Warn().Str("upstream", b.name).Dur("latency", latency).Bool("failed", failed).Msg("Circuit breaker trial failed, reopened")
+		return
+	}
+
+	b.passed++
+	if b.passed < b.trialRequests {
+		return
+	}
+	b.state = breakerClosed
+	b.buckets = [breakerBuckets]originBucket{}
+	b.logger.Info().Str("upstream", b.name).Msg("Circuit breaker closed")
+}
+
+// open moves the breaker to the open state. Must be called with the mutex held.
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = breakerOpen
+	b.openedAt = now
+	b.opens++
+}
+
+// stats returns a snapshot of the breaker
+func (b *circuitBreaker) stats() BreakerStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	requests, failures, latency := b.totals(time.Now())
+	stats := BreakerStats{
+		Enabled:          b.enabled(),
+		State:            b.state,
+		Requests:         requests,
+		Failures:         failures,
+		ErrorThreshold:   b.errorThreshold,
+		LatencyThreshold: b.latencyThreshold,
+		Opens:            b.opens,
+		Rejected:         b.rejected,
+		StaleServed:      b.staleServed,
+	}
+	if requests > 0 {
+		stats.ErrorRate = float64(failures) / float64(requests)
+		stats.MeanLatency = latency / time.Duration(requests)
+	}
+	if b.state != breakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+		if b.state == breakerOpen {
+			retryAt := b.openedAt.Add(b.cooldown)
+			stats.RetryAt = &retryAt
+		}
+	}
+	return stats
+}
+
+// totals sums the buckets still inside the window. Must be called with the
+// mutex held.
+func (b *circuitBreaker) totals(now time.Time) (int64, int64, time.Duration) {
+	cutoff := now.Add(-b.bucketWidth * breakerBuckets)
+
+	var requests, failures int64
+	var latency time.Duration
+	for _, bucket := range b.buckets {
+		if bucket.start.After(cutoff) {
+			requests += bucket.requests
+			failures += bucket.failures
+			latency += bucket.latency
+		}
+	}
+	return requests, failures, latency
+}
+
+// breakerStats returns every upstream's circuit breaker state
+func (s *Server) breakerStats() map[string]BreakerStats {
+	stats := make(map[string]BreakerStats, len(s.upstreams))
+	for _, up := range s.upstreams {
+		stats[up.name] = up.breaker.stats()
+	}
+	return stats
+}
+
+// breakerHealth reports the breakers as degraded while any is not closed
+func (s *Server) breakerHealth() health.ComponentStatus {
+	stats := s.breakerStats()
+	status := "healthy"
+	for _, breaker := range stats {
+		if breaker.State != breakerClosed {
+			status = "degraded"
+		}
+	}
+	return health.ComponentStatus{Status: status, Details: stats}
+}
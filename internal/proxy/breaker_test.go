@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"cache-proxy/internal/logger"
+
+	"github.com/rs/zerolog"
+)
+
+// feed sends up to n requests through a breaker with the given outcome,
+// stopping once it rejects one
+func feed(b *circuitBreaker, n int, latency time.Duration, failed bool) {
+	for i := 0; i < n; i++ {
+		ticket, ok := b.acquire()
+		if !ok {
+			return
+		}
+		ticket.done(latency, failed)
+	}
+}
+
+// TestCircuitBreakerOpens covers which windows trip the breaker
+func TestCircuitBreakerOpens(t *testing.T) {
+	tests := []struct {
+		name      string
+		errorRate float64
+		latency   time.Duration
+		requests  int
+		failures  int
+		took      time.Duration
+		wantState string
+	}{
+		{"healthy", 0.5, 0, 10, 0, time.Millisecond, breakerClosed},
+		{"errors below threshold", 0.5, 0, 10, 4, time.Millisecond, breakerClosed},
+		{"errors at threshold", 0.5, 0, 10, 5, time.Millisecond, breakerOpen},
+		{"too few requests", 0.5, 0, 4, 4, time.Millisecond, breakerClosed},
+		{"slow", 0, 50 * time.Millisecond, 10, 0, 100 * time.Millisecond, breakerOpen},
+		{"fast", 0, 50 * time.Millisecond, 10, 0, 10 * time.Millisecond, breakerClosed},
+		{"disabled", 0, 0, 10, 10, time.Second, breakerClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker("origin", tt.errorRate, tt.latency, 5, time.Minute, time.Minute, 1, logger.NewWithLevel(zerolog.Disabled))
+			feed(b, tt.requests-tt.failures, tt.took, false)
+			feed(b, tt.failures, tt.took, true)
+			if got := b.stats().State; got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+// TestCircuitBreakerRecovery walks a breaker from open through half-open
+// back to closed, and checks that a failed trial reopens it
+func TestCircuitBreakerRecovery(t *testing.T) {
+	cooldown := 20 * time.Millisecond
+	b := newCircuitBreaker("origin", 0.5, 0, 2, time.Minute, cooldown, 2, logger.NewWithLevel(zerolog.Disabled))
+
+	feed(b, 2, time.Millisecond, true)
+	if got := b.stats().State; got != breakerOpen {
+		t.Fatalf("state = %s, want open", got)
+	}
+	if _, ok := b.acquire(); ok {
+		t.Fatal("open breaker should reject requests")
+	}
+
+	// After the cool-down only the configured number of trials get through
+	time.Sleep(cooldown)
+	first, ok := b.acquire()
+	if !ok {
+		t.Fatal("half-open breaker should let a trial through")
+	}
+	if got := b.stats().State; got != breakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", got)
+	}
+	second, ok := b.acquire()
+	if !ok {
+		t.Fatal("half-open breaker should let the second trial through")
+	}
+	if _, ok := b.acquire(); ok {
+		t.Fatal("half-open breaker should reject requests beyond its trials")
+	}
+
+	// A failed trial reopens it
+	first.done(time.Millisecond, false)
+	second.done(time.Millisecond, true)
+	if got := b.stats().State; got != breakerOpen {
+		t.Fatalf("state after failed trial = %s, want open", got)
+	}
+
+	// Successful trials close it with a fresh window
+	time.Sleep(cooldown)
+	for i := 0; i < 2; i++ {
+		ticket, ok := b.acquire()
+		if !ok {
+			t.Fatalf("trial %d rejected", i)
+		}
+		ticket.done(time.Millisecond, false)
+	}
+	stats := b.stats()
+	if stats.State != breakerClosed {
+		t.Fatalf("state after successful trials = %s, want closed", stats.State)
+	}
+	if stats.Requests != 0 || stats.Opens != 2 {
+		t.Errorf("requests = %d, opens = %d; want 0 and 2", stats.Requests, stats.Opens)
+	}
+}
+
+// TestCircuitBreakerCancelledTrial checks that a cancelled trial neither
+// closes nor reopens the breaker and frees its slot for another trial
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	cooldown := 20 * time.Millisecond
+	b := newCircuitBreaker("origin", 0.5, 0, 2, time.Minute, cooldown, 1, logger.NewWithLevel(zerolog.Disabled))
+	feed(b, 2, time.Millisecond, true)
+
+	time.Sleep(cooldown)
+	trial, ok := b.acquire()
+	if !ok {
+		t.Fatal("half-open breaker should let a trial through")
+	}
+	trial.cancel()
+	if got := b.stats().State; got != breakerHalfOpen {
+		t.Fatalf("state after cancelled trial = %s, want half_open", got)
+	}
+
+	retrial, ok := b.acquire()
+	if !ok {
+		t.Fatal("cancelled trial should free its slot")
+	}
+	retrial.done(time.Millisecond, true)
+	if got := b.stats().State; got != breakerOpen {
+		t.Errorf("state after failed retrial = %s, want open", got)
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"cache-proxy/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hopHeaders apply to a single connection and must not be forwarded
+// (RFC 9110 section 7.6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// forwardingHeaders carry the chain of proxies a request passed through
+var forwardingHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+}
+
+// originRequest is what is sent to an upstream: the method, the Host the
+// client asked for, the path and query to fetch, and the headers and body.
+// A body is either buffered, so it can be sent again, or an upload that
+// can only be sent once. Only requests with a client to relay to may stream.
+// The deadline is when the upstream timeout runs out across all attempts.
+type originRequest struct {
+	method        string
+	host          string
+	target        *url.URL
+	header        http.Header
+	body          []byte
+	upload        io.Reader
+	contentLength int64 // of upload; -1 when unknown
+	stream        bool
+	deadline      time.Time
+}
+
+// replayable reports whether the request can be sent more than once
+func (r *originRequest) replayable() bool {
+	return r.upload == nil
+}
+
+// removeHopHeaders deletes hop-by-hop headers, including any named in
+// Connection
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// outgoingHeader returns the headers to send upstream for the client's
+// request: hop-by-hop headers are removed and this hop is added to the
+// configured forwarding headers. Forwarding headers from the client are
+// only kept, and appended to, when it is a trusted proxy.
+func (s *Server) outgoingHeader(c *gin.Context) http.Header {
+	header := c.Request.Header.Clone()
+	removeHopHeaders(header)
+
+	clientIP := c.RemoteIP()
+	if !middleware.ContainsIP(s.trustedProxies, clientIP) {
+		for _, name := range forwardingHeaders {
+			header.Del(name)
+		}
+	}
+
+	proto := "http"
+	if c.Request.TLS != nil {
+		proto = "https"
+	}
+	mode := s.config.ForwardedHeaders
+
+	if mode == "x-forwarded" || mode == "both" {
+		// Requests made inside the proxy, such as cache warming, have no client
+		if clientIP != "" {
+			appendHeader(header, "X-Forwarded-For", clientIP)
+		}
+		// The first proxy saw the client's scheme and host; keep them
+		if header.Get("X-Forwarded-Proto") == "" {
+			header.Set("X-Forwarded-Proto", proto)
+		}
+		if header.Get("X-Forwarded-Host") == "" {
+			header.Set("X-Forwarded-Host", c.Request.Host)
+		}
+	}
+	if mode == "forwarded" || mode == "both" {
+		element := "for=" + forwardedNode(clientIP) + ";host=" + forwardedValue(c.Request.Host) + ";proto=" + proto
+		appendHeader(header, "Forwarded", element)
+	}
+	return header
+}
+
+// appendHeader adds value to the end of a comma-separated list header,
+// folding multiple incoming lines into one
+func appendHeader(header http.Header, name, value string) {
+	if prior := header.Values(name); len(prior) > 0 {
+		value = strings.Join(prior, ", ") + ", " + value
+	}
+	header.Set(name, value)
+}
+
+// forwardedNode formats an IP address as a Forwarded node; IPv6 addresses
+// are bracketed and quoted (RFC 7239 section 6)
+func forwardedNode(ip string) string {
+	if ip == "" {
+		return "unknown"
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return `"[` + ip + `]"`
+	}
+	return forwardedValue(ip)
+}
+
+// forwardedValue quotes a Forwarded parameter value unless it is a token
+func forwardedValue(value string) string {
+	for _, r := range value {
+		if !isTokenChar(r) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	if value == "" {
+		return `""`
+	}
+	return value
+}
+
+// isTokenChar reports whether r may appear in an HTTP token
+func isTokenChar(r rune) bool {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
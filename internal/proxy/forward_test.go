@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cache-proxy/internal/config"
+	"cache-proxy/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRemoveHopHeaders covers the fixed hop-by-hop headers and those named
+// in Connection
+func TestRemoveHopHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []string
+	}{
+		{
+			"fixed hop headers",
+			http.Header{"Keep-Alive": {"timeout=5"}, "Transfer-Encoding": {"chunked"}, "Te": {"trailers"}, "Accept": {"*/*"}},
+			[]string{"Accept"},
+		},
+		{
+			"named in connection",
+			http.Header{"Connection": {"close, X-Session"}, "X-Session": {"abc"}, "X-Trace": {"1"}},
+			[]string{"X-Trace"},
+		},
+		{
+			"several connection lines",
+			http.Header{"Connection": {"X-One", " x-two "}, "X-One": {"1"}, "X-Two": {"2"}, "Cookie": {"a=b"}},
+			[]string{"Cookie"},
+		},
+		{
+			"empty connection tokens",
+			http.Header{"Connection": {", ,"}, "Accept": {"*/*"}},
+			[]string{"Accept"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removeHopHeaders(tt.header)
+			if len(tt.header) != len(tt.want) {
+				t.Errorf("headers left = %v, want only %v", tt.header, tt.want)
+			}
+			for _, name := range tt.want {
+				if tt.header.Get(name) == "" {
+					t.Errorf("%s should be kept", name)
+				}
+			}
+		})
+	}
+}
+
+// TestOutgoingHeader covers the forwarding chain for trusted and untrusted
+// peers in each forwarding mode
+func TestOutgoingHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		peer          string
+		incoming      http.Header
+		wantFor       string
+		wantProto     string
+		wantHost      string
+		wantForwarded string
+	}{
+		{
+			name:      "untrusted peer chain is dropped",
+			mode:      "x-forwarded",
+			peer:      "203.0.113.7",
+			incoming:  http.Header{"X-Forwarded-For": {"10.0.0.1"}, "X-Forwarded-Host": {"spoofed.test"}, "Forwarded": {"for=10.0.0.1"}},
+			wantFor:   "203.0.113.7",
+			wantProto: "http",
+			wantHost:  "site.test",
+		},
+		{
+			name:      "trusted peer chain is extended",
+			mode:      "x-forwarded",
+			peer:      "10.1.2.3",
+			incoming:  http.Header{"X-Forwarded-For": {"198.51.100.1", "198.51.100.2"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"public.test"}},
+			wantFor:   "198.51.100.1, 198.51.100.2, 10.1.2.3",
+			wantProto: "https",
+			wantHost:  "public.test",
+		},
+		{
+			name:          "forwarded from untrusted peer",
+			mode:          "forwarded",
+			peer:          "203.0.113.7",
+			incoming:      http.Header{"Forwarded": {"for=10.0.0.1"}},
+			wantForwarded: "for=203.0.113.7;host=site.test;proto=http",
+		},
+		{
+			name:          "forwarded from trusted peer",
+			mode:          "forwarded",
+			peer:          "10.1.2.3",
+			incoming:      http.Header{"Forwarded": {"for=198.51.100.1"}},
+			wantForwarded: "for=198.51.100.1, for=10.1.2.3;host=site.test;proto=http",
+		},
+		{
+			name:          "ipv6 peer in both",
+			mode:          "both",
+			peer:          "2001:db8::1",
+			wantFor:       "2001:db8::1",
+			wantProto:     "http",
+			wantHost:      "site.test",
+			wantForwarded: `for="[2001:db8::1]";host=site.test;proto=http`,
+		},
+		{
+			name:     "none strips untrusted headers",
+			mode:     "none",
+			peer:     "203.0.113.7",
+			incoming: http.Header{"X-Forwarded-For": {"10.0.0.1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.ForwardedHeaders = tt.mode
+			s := &Server{config: cfg, trustedProxies: middleware.ParseNetworks([]string{"10.0.0.0/8"})}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/page", nil)
+			c.Request.Host = "site.test"
+			c.Request.RemoteAddr = net.JoinHostPort(tt.peer, "40000")
+			for name, values := range tt.incoming {
+				c.Request.Header[name] = values
+			}
+
+			header := s.outgoingHeader(c)
+			for name, want := range map[string]string{
+				"X-Forwarded-For":   tt.wantFor,
+				"X-Forwarded-Proto": tt.wantProto,
+				"X-Forwarded-Host":  tt.wantHost,
+				"Forwarded":         tt.wantForwarded,
+			} {
+				if got := header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestHostHeaderPolicy checks that the origin sees the client's Host when
+// it is preserved and its own address when it is rewritten
+func TestHostHeaderPolicy(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+
+	for policy, want := range map[string]string{"preserve": "site.test", "origin": "127.0.0.1:"} {
+		t.Run(policy, func(t *testing.T) {
+			s := newTestServer(t, origin, func(cfg *config.Config) {
+				cfg.HostHeader = policy
+			})
+			rec := serve(s, http.MethodGet, "site.test", "/page", "")
+			if !strings.HasPrefix(rec.Body.String(), want) {
+				t.Errorf("origin saw Host %q, want %q", rec.Body.String(), want)
+			}
+		})
+	}
+}
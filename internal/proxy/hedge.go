@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"cache-proxy/internal/balancer"
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/config"
+	"cache-proxy/internal/errors"
+)
+
+const (
+	// hedgeSamples is how many recent latencies the hedge delay is computed from
+	hedgeSamples = 512
+	// hedgeMinSamples is how many latencies a route needs before it hedges
+	hedgeMinSamples = 20
+	// hedgeRecompute is how many new samples trigger recomputing the delay
+	hedgeRecompute = 32
+	// defaultHedgeBudget is the fraction of requests that may be hedged
+	// when the route doesn't set one
+	defaultHedgeBudget = 0.1
+)
+
+// HedgeStats reports a route's hedge delay and how often hedges win
+type HedgeStats struct {
+	Percentile float64       `json:"percentile"`
+	Delay      time.Duration `json:"delay"`
+	Samples    int           `json:"samples"`
+	Hedged     int64         `json:"hedged"`
+	Wins       int64         `json:"wins"`
+	WinRate    float64       `json:"win_rate"`
+	Budget     RetryStats    `json:"budget"`
+}
+
+// hedger sends a second request to another target when the first is slower
+// than a percentile of the route's recent latencies. Hedges draw on the same
+// kind of windowed budget as retries.
+type hedger struct {
+	percentile float64
+	minDelay   time.Duration
+	budget     *retryBudget
+
+	mutex    sync.Mutex
+	samples  [hedgeSamples]time.Duration
+	count    int
+	next     int
+	pending  int // samples since the delay was computed
+	computed bool
+	delay    time.Duration
+	wins     int64
+}
+
+// newHedger creates a hedger for a route, or nil when hedging is off
+func newHedger(hc config.HedgeConfig) *hedger {
+	if hc.Percentile <= 0 {
+		return nil
+	}
+	budget := hc.Budget
+	if budget <= 0 {
+		budget = defaultHedgeBudget
+	}
+	return &hedger{
+		percentile: hc.Percentile,
+		minDelay:   time.Duration(hc.MinDelay),
+		budget:     newRetryBudget(budget, 0),
+	}
+}
+
+// observe records the latency of a successful origin request
+func (h *hedger) observe(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+	if h.count < hedgeSamples {
+		h.count++
+	}
+	h.pending++
+}
+
+// hedgeDelay returns how long to wait before hedging, and false until there
+// are enough samples to know what slow looks like
+func (h *hedger) hedgeDelay() (time.Duration, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.count < hedgeMinSamples {
+		return 0, false
+	}
+	if !h.computed || h.pending >= hedgeRecompute {
+		sorted := make([]time.Duration, h.count)
+		copy(sorted, h.samples[:h.count])
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		h.delay = sorted[int(h.percentile*float64(h.count-1))]
+		h.pending = 0
+		h.computed = true
+	}
+	if h.delay < h.minDelay {
+		return h.minDelay, true
+	}
+	return h.delay, true
+}
+
+// won counts a hedge that answered before the original request
+func (h *hedger) won() {
+	h.mutex.Lock()
+	h.wins++
+	h.mutex.Unlock()
+}
+
+// stats returns a snapshot of the hedger
+func (h *hedger) stats() HedgeStats {
+	delay, _ := h.hedgeDelay()
+	budget := h.budget.stats()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	stats := HedgeStats{
+		Percentile: h.percentile,
+		Delay:      delay,
+		Samples:    h.count,
+		Hedged:     budget.Total,
+		Wins:       h.wins,
+		Budget:     budget,
+	}
+	if stats.Hedged > 0 {
+		stats.WinRate = float64(h.wins) / float64(stats.Hedged)
+	}
+	return stats
+}
+
+// hedgeResult is the outcome of one of the racing requests
+type hedgeResult struct {
+	entry  *cache.Entry
+	stream io.ReadCloser
+	appErr *errors.AppError
+	hedge  bool
+}
+
+// fetchHedged sends the request to primary and, if it hasn't answered within
+// the route's hedge delay, to a second target as well. The first successful
+// answer wins and the other request is cancelled.
+func (s *Server) fetchHedged(ctx context.Context, rt *route, cacheKey string, primary *balancer.Target, request *originRequest) (*cache.Entry, io.ReadCloser, *errors.AppError) {
+	rt.hedge.budget.request()
+	delay, ok := rt.hedge.hedgeDelay()
+	if !ok {
+		return s.fetchOnce(ctx, rt, primary, request)
+	}
+
+	results := make(chan hedgeResult, 2)
+	race := func(ctx context.Context, member *balancer.Target, hedge bool) {
+		entry, stream, appErr := s.fetchOnce(ctx, rt, member, request)
+		results <- hedgeResult{entry: entry, stream: stream, appErr: appErr, hedge: hedge}
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	go race(primaryCtx, primary, false)
+
+	timer := time.NewTimer(delay)
+	select {
+	case result := <-results:
+		timer.Stop()
+		return result.entry, result.stream, result.appErr
+	case <-timer.C:
+	}
+
+	second := rt.upstream.pool.Pick(cacheKey, primary)
+	if second == nil || !rt.hedge.budget.withdraw() {
+		result := <-results
+		return result.entry, result.stream, result.appErr
+	}
+
+	s.logger.Debug().
+		Str("route", rt.name).
+		Str("upstream", rt.upstream.name).
+		Str("target", second.URL.String()).
+		Dur("delay", delay).
+		Msg("Hedging slow origin request")
+
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+	go race(hedgeCtx, second, true)
+
+	// Take the first success; if the first answer is an error, the other
+	// request may still succeed
+	result := <-results
+	if result.appErr != nil {
+		result = <-results
+	} else {
+		// Cancelling the loser doesn't reach a stream it already handed
+		// over, so close that when it arrives
+		go func() {
+			if loser := <-results; loser.stream != nil {
+				loser.stream.Close()
+			}
+		}()
+	}
+	if result.appErr == nil && result.hedge {
+		rt.hedge.won()
+	}
+	return result.entry, result.stream, result.appErr
+}
+
+// hedgeStats returns hedging stats for every route that hedges
+func (s *Server) hedgeStats() map[string]HedgeStats {
+	stats := make(map[string]HedgeStats)
+	for _, rt := range s.routes {
+		if rt.hedge != nil {
+			stats[rt.name] = rt.hedge.stats()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bumpNamespaceRequest is the body accepted by POST /cache/namespace/bump.
+// An empty zone bumps every zone and an empty host bumps the zone-wide
+// generation instead of one host's.
+type bumpNamespaceRequest struct {
+	Zone string `json:"zone"`
+	Host string `json:"host"`
+}
+
+// handleGetNamespace returns the namespace and generations of every zone
+func (s *Server) handleGetNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespaces := make(map[string]cache.NamespaceInfo, len(s.zones))
+		for _, z := range s.zones {
+			namespaces[z.name] = z.cache.Namespace()
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"zones":     namespaces,
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// handleBumpNamespace invalidates a zone, a host or the whole cache at once
+// by bumping the generation folded into its keys
+func (s *Server) handleBumpNamespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req bumpNamespaceRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_NAMESPACE_BUMP", "Request body must be JSON with optional zone and host fields", http.StatusBadRequest)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+		}
+
+		zones := s.zones
+		if req.Zone != "" {
+			z, exists := s.zoneByName(req.Zone)
+			if !exists {
+				appErr := errors.New(errors.ErrorTypeNotFound, "ZONE_NOT_FOUND", "No cache zone with that name", http.StatusNotFound)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			zones = []*zone{z}
+		}
+
+		generations := make(map[string]int64, len(zones))
+		for _, z := range zones {
+			generations[z.name] = z.cache.BumpNamespace(req.Host)
+		}
+
+		s.logger.Warn().
+			Str("zone", req.Zone).
+			Str("host", req.Host).
+			Interface("generations", generations).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Cache namespace bumped")
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Namespace bumped",
+			"zone":        req.Zone,
+			"host":        req.Host,
+			"generations": generations,
+			"timestamp":   time.Now(),
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"cache-proxy/internal/config"
+)
+
+// TestBumpZoneNamespace checks that bumping one zone turns its cached
+// responses into misses while other zones keep serving hits
+func TestBumpZoneNamespace(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	s := newTestServer(t, origin, func(cfg *config.Config) {
+		cfg.Zones = []config.ZoneConfig{{Name: "static", PathPrefixes: []string{"/static"}}}
+	})
+
+	paths := []string{"/static/app.js", "/page"}
+	for _, path := range paths {
+		serve(s, http.MethodGet, "site.test", path, "")
+		if rec := serve(s, http.MethodGet, "site.test", path, ""); rec.Header().Get("X-Cache") != "HIT" {
+			t.Fatalf("GET %s: X-Cache = %q, want HIT", path, rec.Header().Get("X-Cache"))
+		}
+	}
+
+	if rec := serve(s, http.MethodPost, "site.test", "/cache/namespace/bump", `{"zone":"static"}`); rec.Code != http.StatusOK {
+		t.Fatalf("bump status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	want := map[string]string{"/static/app.js": "MISS", "/page": "HIT"}
+	for _, path := range paths {
+		if rec := serve(s, http.MethodGet, "site.test", path, ""); rec.Header().Get("X-Cache") != want[path] {
+			t.Errorf("GET %s after bump: X-Cache = %q, want %s", path, rec.Header().Get("X-Cache"), want[path])
+		}
+	}
+}
@@ -1,42 +1,56 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	stderrors "errors"
 	"io"
+	"net"
 	"net/http"
-	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"cache-proxy/internal/balancer"
 	"cache-proxy/internal/cache"
 	"cache-proxy/internal/config"
 	"cache-proxy/internal/errors"
 	"cache-proxy/internal/health"
 	"cache-proxy/internal/logger"
+	"cache-proxy/internal/memory"
 	"cache-proxy/internal/middleware"
+	"cache-proxy/internal/warmer"
 
 	"github.com/gin-gonic/gin"
 )
 
+// errBodyTimeout cancels an origin request whose body takes too long to read
+var errBodyTimeout = stderrors.New("origin response body timeout")
+
+// errOriginTimeout cancels an origin request that exceeds the upstream timeout
+var errOriginTimeout = stderrors.New("origin request timeout")
+
 // Server represents the caching proxy server with enterprise features
 type Server struct {
-	cache         cache.Cache
-	originURL     *url.URL
-	router        *gin.Engine
-	logger        logger.Logger
-	config        *config.Config
-	healthService *health.Service
-	httpServer    *http.Server
-	client        *http.Client
+	zones          []*zone
+	routes         []*route
+	upstreams      []*upstream
+	trustedProxies []*net.IPNet
+	router         *gin.Engine
+	logger         logger.Logger
+	config         *config.Config
+	healthService  *health.Service
+	httpServer     *http.Server
+	warmer         *warmer.Warmer
+	refresher      *refresher
+	adaptive       *adaptiveTTL
+	retry          *retryPolicy
+	upgrades       *upgradeTracker
+	memoryMonitor  *memory.Monitor
 }
 
 // New creates a new proxy server instance with enterprise configuration
 func New(cfg *config.Config, cacheInstance cache.Cache, log logger.Logger) (*Server, error) {
-	parsedURL, err := url.Parse(cfg.Origin)
-	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ORIGIN_URL", "failed to parse origin URL", http.StatusBadRequest)
-	}
-
 	// Set Gin mode based on log level
 	if cfg.LogLevel == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -46,41 +60,101 @@ func New(cfg *config.Config, cacheInstance cache.Cache, log logger.Logger) (*Ser
 
 	router := gin.New()
 
+	// Only trusted proxies may set the client IP gin reports in logs
+	trusted := make([]string, 0, len(cfg.TrustedProxies))
+	for _, entry := range cfg.TrustedProxies {
+		trusted = append(trusted, strings.TrimSpace(entry))
+	}
+	if err := router.SetTrustedProxies(trusted); err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_TRUSTED_PROXY", "trusted proxies must be IP addresses or CIDR ranges", http.StatusBadRequest)
+	}
+
 	// Add enterprise middleware
 	router.Use(middleware.RequestID())
 	router.Use(middleware.LoggerMiddleware(log))
 	router.Use(middleware.SecurityHeaders())
 	router.Use(gin.Recovery())
-	
+
 	if cfg.EnableCORS {
 		router.Use(middleware.CORS())
 	}
-	
+
 	router.Use(middleware.MetricsMiddleware())
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: cfg.Timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:       100,
-			IdleConnTimeout:    90 * time.Second,
-			DisableCompression: false,
-		},
+	// Every upstream gets its own client over a copy of this transport with
+	// the upstream's connection timeouts
+	transport := &http.Transport{
+		MaxIdleConns:       100,
+		IdleConnTimeout:    90 * time.Second,
+		DisableCompression: false,
+	}
+	routes, upstreams, err := newRoutes(cfg, transport, log)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_ORIGIN_URL", "failed to parse origin URL", http.StatusBadRequest)
 	}
 
 	// Create health service
 	healthService := health.NewService(cacheInstance, log, "1.0.0")
 
+	statusPolicy := cache.StatusPolicy{
+		SuccessTTL:           cfg.CacheTTL,
+		NegativeTTL:          cfg.NegativeCacheTTL,
+		CacheServerErrors:    cfg.CacheServerErrors,
+		ServerErrorTTL:       cfg.ServerErrorCacheTTL,
+		PermanentRedirectTTL: cfg.PermanentRedirectTTL,
+		TemporaryRedirectTTL: cfg.TemporaryRedirectTTL,
+	}
+
+	// Configured zones are matched in order; the default zone takes everything else
+	zones := make([]*zone, 0, len(cfg.Zones)+1)
+	for _, zc := range cfg.Zones {
+		zones = append(zones, newZone(zc, cfg, statusPolicy))
+	}
+	zones = append(zones, &zone{name: defaultZoneName, cache: cacheInstance, policy: statusPolicy})
+
+	// Stretch TTLs whenever freshness is checked, so they return to normal
+	// as soon as the origin recovers
+	adaptive := newAdaptiveTTL(cfg.AdaptiveTTLLatency, cfg.AdaptiveTTLErrorRate, cfg.AdaptiveTTLFactor, cfg.AdaptiveTTLMax, cfg.AdaptiveTTLWindow, log)
+	if adaptive.enabled() {
+		for _, z := range zones {
+			z.cache.SetTTLExtension(adaptive.extend)
+		}
+	}
+
 	server := &Server{
-		cache:         cacheInstance,
-		originURL:     parsedURL,
-		router:        router,
-		logger:        log,
-		config:        cfg,
-		healthService: healthService,
-		client:        client,
+		zones:          zones,
+		routes:         routes,
+		upstreams:      upstreams,
+		trustedProxies: middleware.ParseNetworks(cfg.TrustedProxies),
+		router:         router,
+		logger:         log,
+		config:         cfg,
+		healthService:  healthService,
+		refresher:      newRefresher(cfg.RefreshAheadFraction, cfg.RefreshMinHits, cfg.RefreshConcurrency, cfg.Timeout),
+		retry:          newRetryPolicy(cfg.RetryAttempts, cfg.RetryBackoff, cfg.RetryMaxBackoff, cfg.RetryOn),
+		upgrades:       newUpgradeTracker(cfg.UpgradeIdleTimeout),
+		adaptive:       adaptive,
 	}
 
+	// Warming replays requests through the router so they follow the normal caching path
+	server.warmer = warmer.New(router, log, warmer.Options{
+		Concurrency: cfg.WarmConcurrency,
+		Rate:        cfg.WarmRate,
+		Host:        cfg.WarmHost,
+	})
+
+	// Evict under heap pressure when a soft memory limit is configured
+	if cfg.MemorySoftLimit > 0 {
+		server.memoryMonitor = memory.NewMonitor(memory.Config{
+			SoftLimit: cfg.MemorySoftLimit,
+			LowWater:  cfg.MemoryLowWater,
+			Interval:  cfg.MemoryCheckInterval,
+		}, log, server.caches()...)
+		healthService.AddComponent("memory", server.memoryHealth)
+	}
+	healthService.AddComponent("upstreams", server.upstreamHealth)
+	healthService.AddComponent("circuit_breakers", server.breakerHealth)
+
 	// Register routes
 	server.registerRoutes()
 
@@ -88,6 +162,8 @@ func New(cfg *config.Config, cacheInstance cache.Cache, log logger.Logger) (*Ser
 		Str("origin", cfg.Origin).
 		Int("port", cfg.Port).
 		Str("host", cfg.Host).
+		Int("zones", len(zones)).
+		Int("routes", len(routes)).
 		Msg("Created new proxy server")
 
 	return server, nil
@@ -106,33 +182,90 @@ func (s *Server) registerRoutes() {
 	s.router.GET("/cache/stats", s.handleCacheStats())
 	s.router.DELETE("/cache", s.handleCacheClear())
 
-	// Proxy all other requests
-	s.router.Any("/*path", s.handleProxy)
+	// Cache inspection and control endpoints
+	adminAuth := middleware.AdminAuth(s.config.AdminToken, s.config.AdminAllowedIPs)
+	admin := s.router.Group("/cache", adminAuth)
+	admin.GET("/entries", s.handleListEntries())
+	admin.GET("/entries/:key", s.handleGetEntry())
+	admin.PUT("/entries", s.handlePushEntry())
+	admin.PUT("/entries/:key/pin", s.handlePinEntry(true))
+	admin.DELETE("/entries/:key/pin", s.handlePinEntry(false))
+	admin.PUT("/entries/:key/freeze", s.handleFreezeEntry(true))
+	admin.DELETE("/entries/:key/freeze", s.handleFreezeEntry(false))
+	admin.GET("/versions", s.handleListVersions())
+	admin.POST("/versions/promote", s.handlePromoteVersion())
+	admin.GET("/namespace", s.handleGetNamespace())
+	admin.POST("/namespace/bump", s.handleBumpNamespace())
+	admin.POST("/warm", s.handleStartWarm())
+	admin.GET("/warm", s.handleListWarm())
+	admin.GET("/warm/:id", s.handleGetWarm())
+	admin.DELETE("/warm/:id", s.handleCancelWarm())
+
+	// Varnish-style invalidation arrives on the proxy listener itself
+	s.router.Handle("PURGE", "/*path", adminAuth, s.handlePurge)
+	s.router.Handle("BAN", "/*path", adminAuth, s.handleBan)
+
+	// Proxy all other requests; a catch-all route would conflict with the
+	// fixed routes above, so unmatched requests fall through to NoRoute
+	s.router.NoRoute(s.handleProxy)
 }
 
 // handleCacheStats returns cache statistics
 func (s *Server) handleCacheStats() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		stats := s.cache.Stats()
-		c.JSON(http.StatusOK, gin.H{
-			"cache_stats": stats,
-			"timestamp": time.Now(),
-		})
+		zoneStats, stats := s.zoneStats()
+		response := gin.H{
+			"cache_stats":      stats,
+			"zones":            zoneStats,
+			"refresh_ahead":    s.refresher.stats(),
+			"adaptive_ttl":     s.adaptive.stats(),
+			"upstreams":        s.upstreamStats(),
+			"circuit_breakers": s.breakerStats(),
+			"retries":          s.retryStats(),
+			"hedging":          s.hedgeStats(),
+			"upgrades":         s.upgrades.stats(),
+			"timestamp":        time.Now(),
+		}
+		if s.memoryMonitor != nil {
+			response["memory"] = s.memoryMonitor.Stats()
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
 
-// handleCacheClear clears the cache
+// memoryHealth reports the memory monitor as degraded while under pressure
+func (s *Server) memoryHealth() health.ComponentStatus {
+	stats := s.memoryMonitor.Stats()
+	status := "healthy"
+	if stats.UnderPressure {
+		status = "degraded"
+	}
+	return health.ComponentStatus{Status: status, Details: stats}
+}
+
+// handleCacheClear clears every zone, or only the one named by ?zone=
 func (s *Server) handleCacheClear() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := s.cache.Clear(); err != nil {
+		zones := s.zones
+		if name := c.Query("zone"); name != "" {
+			z, exists := s.zoneByName(name)
+			if !exists {
+				appErr := errors.New(errors.ErrorTypeNotFound, "ZONE_NOT_FOUND", "No cache zone with that name", http.StatusNotFound)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			zones = []*zone{z}
+		}
+
+		if err := clearZones(zones); err != nil {
 			s.logger.Error().Err(err).Msg("Failed to clear cache")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cache"})
 			return
 		}
-		
-		s.logger.Info().Msg("Cache cleared via API")
+
+		s.logger.Info().Str("zone", c.Query("zone")).Msg("Cache cleared via API")
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Cache cleared successfully",
+			"message":   "Cache cleared successfully",
 			"timestamp": time.Now(),
 		})
 	}
@@ -140,123 +273,432 @@ func (s *Server) handleCacheClear() gin.HandlerFunc {
 
 // handleProxy handles all incoming requests and implements caching logic
 func (s *Server) handleProxy(c *gin.Context) {
-	cacheKey := s.cache.GenerateKey(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery)
+	rt, ok := s.routeFor(c.Request.Host, c.Request.URL.Path)
+	if !ok {
+		appErr := errors.New(errors.ErrorTypeNotFound, "ROUTE_NOT_FOUND", "No route matches the request", http.StatusNotFound)
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	// Protocol switches such as WebSocket bypass the cache entirely
+	if isUpgrade(c.Request) {
+		s.handleUpgrade(c, rt)
+		return
+	}
+
+	z := s.zoneForRoute(rt, c.Request.Host, c.Request.URL.Path)
+	cacheKey := z.cache.GenerateKey(rt.name, c.Request.Method, c.Request.Host, c.Request.URL.Path, c.Request.URL.RawQuery)
 
 	s.logger.Debug().
 		Str("method", c.Request.Method).
 		Str("path", c.Request.URL.Path).
+		Str("route", rt.name).
+		Str("zone", z.name).
 		Str("cache_key", cacheKey).
 		Str("request_id", c.GetString("request_id")).
 		Msg("Processing request")
 
-	if entry, exists := s.cache.Get(cacheKey); exists {
+	if rt.upstream.noCache || rt.stream {
+		s.forwardToOrigin(c, rt, z, cacheKey)
+		return
+	}
+
+	if entry, exists := z.cache.Get(cacheKey); exists {
 		s.logger.Info().
+			Str("route", rt.name).
+			Str("zone", z.name).
 			Str("cache_key", cacheKey).
 			Str("request_id", c.GetString("request_id")).
 			Msg("Cache hit")
-		s.serveFromCache(c, entry)
+		s.serveEntry(c, entry, "HIT")
+		s.maybeRefresh(z, cacheKey, entry)
 		return
 	}
 
 	s.logger.Info().
+		Str("route", rt.name).
+		Str("zone", z.name).
 		Str("cache_key", cacheKey).
 		Str("request_id", c.GetString("request_id")).
 		Msg("Cache miss - forwarding to origin")
-	s.forwardToOrigin(c, cacheKey)
+	s.forwardToOrigin(c, rt, z, cacheKey)
 }
 
-// serveFromCache serves response from cache
-func (s *Server) serveFromCache(c *gin.Context, entry *cache.Entry) {
+// serveEntry serves a response from cache, reporting cacheStatus in X-Cache
+func (s *Server) serveEntry(c *gin.Context, entry *cache.Entry, cacheStatus string) {
 	// Copy headers from cache
 	for key, values := range entry.Headers {
 		for _, value := range values {
 			c.Header(key, value)
 		}
 	}
-	c.Header("X-Cache", "HIT")
+	c.Header("X-Cache", cacheStatus)
 	c.Data(entry.Status, entry.Headers.Get("Content-Type"), entry.Body)
 }
 
-// forwardToOrigin forwards request to origin server and caches response
-func (s *Server) forwardToOrigin(c *gin.Context, cacheKey string) {
-	ctx := context.WithValue(c.Request.Context(), "request_id", c.GetString("request_id"))
-	
-	originURL := *s.originURL
-	originURL.Path = c.Request.URL.Path
-	originURL.RawQuery = c.Request.URL.RawQuery
+// forwardToOrigin forwards request to the route's upstream and caches the
+// response in z
+func (s *Server) forwardToOrigin(c *gin.Context, rt *route, z *zone, cacheKey string) {
+	ctx, cancel := s.requestContext(c, rt)
+	defer cancel()
+
+	request := &originRequest{
+		method: c.Request.Method,
+		host:   c.Request.Host,
+		target: c.Request.URL,
+		header: s.outgoingHeader(c),
+		stream: true,
+	}
+	if appErr := s.readRequestBody(c, rt, request); appErr != nil {
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	entry, stream, appErr := s.fetchFromOrigin(ctx, rt, cacheKey, request)
+	if appErr == errors.ErrCircuitOpen && !rt.upstream.noCache {
+		if stale, exists := z.cache.GetStale(cacheKey); exists {
+			rt.upstream.breaker.servedStale()
+			s.logger.Info().Str("route", rt.name).Str("zone", z.name).Str("cache_key", cacheKey).Msg("Circuit open - serving stale entry")
+			s.serveEntry(c, stale, "STALE")
+			return
+		}
+	}
+	if appErr != nil {
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+	if stream != nil {
+		s.streamResponse(c, rt, entry, stream)
+		return
+	}
+	entry.Host = c.Request.Host
+
+	// Store in cache if the status policy allows it
+	if ttl, cacheable := policyFor(z, rt).TTLFor(entry.Status); cacheable && !rt.upstream.noCache {
+		entry.TTL = ttl
+		if err := z.cache.Set(cacheKey, entry); err == cache.ErrNotAdmitted {
+			s.logger.Debug().Str("zone", z.name).Str("cache_key", cacheKey).Msg("Entry not admitted to cache")
+		} else if err == cache.ErrFrozen {
+			s.logger.Debug().Str("zone", z.name).Str("cache_key", cacheKey).Msg("Cached entry is frozen, not replacing it")
+		} else if err != nil {
+			s.logger.Error().Err(err).Str("zone", z.name).Str("cache_key", cacheKey).Msg("Failed to store entry in cache")
+		}
+	}
+
+	// Send response to client
+	for key, values := range entry.Headers {
+		for _, value := range values {
+			c.Header(key, value)
+		}
+	}
+	c.Header("X-Cache", "MISS")
+	c.Data(entry.Status, entry.Headers.Get("Content-Type"), entry.Body)
+}
+
+// readRequestBody buffers the client's body when a retry or hedge may send
+// it again and it fits the retry body limit. Other bodies, including large
+// ones, are streamed to the origin as an upload that is sent only once.
+func (s *Server) readRequestBody(c *gin.Context, rt *route, request *originRequest) *errors.AppError {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+
+	replays := s.retry.allows(request.method) || (rt.hedge != nil && idempotentMethods[request.method])
+	limit := s.config.RetryBodyLimit
+	if !replays || c.Request.ContentLength > limit {
+		request.upload, request.contentLength = c.Request.Body, c.Request.ContentLength
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+	if err != nil {
+		return errors.Wrap(err, errors.ErrorTypeValidation, "REQUEST_BODY_READ_FAILED", "Failed to read request body", http.StatusBadRequest)
+	}
+	if int64(len(body)) > limit {
+		// A body of unknown length turned out too large; send what was read
+		// followed by the rest
+		request.upload, request.contentLength = io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.ContentLength
+		return nil
+	}
+	request.body = body
+	return nil
+}
+
+// fetchFromOrigin sends a request for the target's path and query to a member
+// of the route's upstream pool, chosen by cacheKey where the strategy uses
+// it, and reads the full response into a cache entry, or returns the body
+// unread when it is a stream the request allows. Idempotent requests that
+// fail or get a retryable status are retried on another member when the
+// pool has one, within the upstream's retry budget; streams never are.
+func (s *Server) fetchFromOrigin(ctx context.Context, rt *route, cacheKey string, request *originRequest) (*cache.Entry, io.ReadCloser, *errors.AppError) {
+	// The upstream timeout bounds every attempt together, not each one
+	request.deadline = time.Now().Add(rt.upstream.timeout)
+	retryable := s.retry.allows(request.method) && request.replayable()
+	rt.upstream.retries.request()
+
+	var tried []*balancer.Target
+	for attempt := 0; ; attempt++ {
+		member := rt.upstream.pool.Pick(cacheKey, tried...)
+		if member == nil && len(tried) > 0 {
+			member = rt.upstream.pool.Pick(cacheKey)
+		}
+		if member == nil {
+			appErr := errors.New(errors.ErrorTypeNetwork, "NO_HEALTHY_UPSTREAM", "No healthy upstream server is available", http.StatusServiceUnavailable)
+			s.logger.Error().Err(appErr).Str("route", rt.name).Str("upstream", rt.upstream.name).Msg("No healthy upstream target")
+			return nil, nil, appErr
+		}
+		tried = append(tried, member)
+
+		var entry *cache.Entry
+		var stream io.ReadCloser
+		var appErr *errors.AppError
+		if rt.hedge != nil && idempotentMethods[request.method] && request.replayable() {
+			entry, stream, appErr = s.fetchHedged(ctx, rt, cacheKey, member, request)
+		} else {
+			entry, stream, appErr = s.fetchOnce(ctx, rt, member, request)
+		}
+		if stream != nil || !retryable || attempt >= s.retry.attempts || !s.shouldRetry(ctx, entry, appErr) {
+			return entry, stream, appErr
+		}
 
-	req, err := http.NewRequestWithContext(ctx, c.Request.Method, originURL.String(), c.Request.Body)
+		var retryAfter string
+		if entry != nil {
+			retryAfter = entry.Headers.Get("Retry-After")
+		}
+		delay, ok := s.retry.delay(attempt+1, retryAfter)
+		if !ok {
+			return entry, nil, appErr
+		}
+		if !fitsDeadline(ctx, request.deadline, delay) {
+			s.logger.Debug().Str("route", rt.name).Str("upstream", rt.upstream.name).Dur("delay", delay).Msg("Retry delay exceeds the deadline, not retrying")
+			return entry, nil, appErr
+		}
+		if !rt.upstream.retries.withdraw() {
+			s.logger.Warn().Str("route", rt.name).Str("upstream", rt.upstream.name).Msg("Retry budget exhausted, not retrying")
+			return entry, nil, appErr
+		}
+
+		event := s.logger.Info().
+			Str("route", rt.name).
+			Str("upstream", rt.upstream.name).
+			Str("target", member.URL.String()).
+			Int("attempt", attempt+1).
+			Dur("delay", delay)
+		if entry != nil {
+			event = event.Int("status", entry.Status)
+		}
+		event.Msg("Retrying origin request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return entry, nil, appErr
+		}
+	}
+}
+
+// shouldRetry reports whether an attempt failed in a way another attempt
+// might fix: a network error or one of the retry-on statuses
+func (s *Server) shouldRetry(ctx context.Context, entry *cache.Entry, appErr *errors.AppError) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if appErr != nil {
+		return appErr.Type == errors.ErrorTypeNetwork && appErr != errors.ErrCircuitOpen
+	}
+	return s.retry.retryOn[entry.Status]
+}
+
+// fetchOnce sends a single request to member and reads the response. When
+// the request may stream and the response is a stream, the body is returned
+// unread instead; it outlives ctx and the caller must close it.
+func (s *Server) fetchOnce(ctx context.Context, rt *route, member *balancer.Target, request *originRequest) (*cache.Entry, io.ReadCloser, *errors.AppError) {
+	originURL := rt.upstreamURL(member, request.target.Path, request.target.RawQuery)
+
+	var reqBody io.Reader
+	if request.upload != nil {
+		reqBody = request.upload
+	} else if len(request.body) > 0 {
+		reqBody = bytes.NewReader(request.body)
+	}
+
+	// The attempt follows ctx only until a stream is handed to the caller,
+	// and is bounded by the request's upstream deadline only until then too
+	attemptCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	detach := context.AfterFunc(ctx, func() { cancel(context.Cause(ctx)) })
+	timeout := time.AfterFunc(time.Until(request.deadline), func() { cancel(errOriginTimeout) })
+	streaming := false
+	defer func() {
+		if !streaming {
+			detach()
+			timeout.Stop()
+			cancel(nil)
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(attemptCtx, request.method, originURL.String(), reqBody)
 	if err != nil {
 		appErr := errors.Wrap(err, errors.ErrorTypeInternal, "REQUEST_CREATION_FAILED", "Failed to create request to origin server", http.StatusInternalServerError)
 		s.logger.Error().Err(appErr).Msg("Request creation failed")
-		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
-		return
+		return nil, nil, appErr
+	}
+	if request.upload != nil {
+		req.ContentLength = request.contentLength
 	}
 
 	// Copy headers from original request
-	for key, values := range c.Request.Header {
+	for key, values := range request.header {
 		for _, value := range values {
 			req.Header.Add(key, value)
 		}
 	}
+	applyHeaderRules(req.Header, rt.upstream.requestHeaders)
+	if rt.upstream.preserveHost && request.host != "" {
+		req.Host = request.host
+	}
 
-	// Make request to origin server using configured client with timeout
-	resp, err := s.client.Do(req)
-	if err != nil {
-		appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "ORIGIN_REQUEST_FAILED", "Failed to reach origin server", http.StatusBadGateway)
-		s.logger.Error().Err(appErr).Str("origin", s.originURL.String()).Msg("Origin request failed")
-		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
-		return
+	ticket, allowed := rt.upstream.breaker.acquire()
+	if !allowed {
+		s.logger.Debug().Str("route", rt.name).Str("upstream", rt.upstream.name).Msg("Circuit open - rejecting origin request")
+		return nil, nil, errors.ErrCircuitOpen
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	// Make request to origin server using configured client with timeout
+	start := time.Now()
+	done := member.Start()
+	resp, err := rt.upstream.client.Do(req)
 	if err != nil {
-		appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "ORIGIN_RESPONSE_READ_FAILED", "Failed to read response from origin server", http.StatusInternalServerError)
-		s.logger.Error().Err(appErr).Msg("Failed to read origin response")
-		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
-		return
+		// A request cancelled by the client or a winning hedge says nothing
+		// about the origin
+		failed := ctx.Err() == nil
+		done(time.Since(start), failed)
+		if failed {
+			ticket.done(time.Since(start), failed)
+		} else {
+			ticket.cancel()
+		}
+		rt.upstream.health.Observe(member, failed)
+		s.adaptive.observe(time.Since(start), failed)
+		appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "ORIGIN_REQUEST_FAILED", "Failed to reach origin server", http.StatusBadGateway)
+		if cause := context.Cause(attemptCtx); cause == errOriginTimeout || isTimeout(cause) || isTimeout(err) {
+			appErr = errors.Wrap(err, errors.ErrorTypeTimeout, "ORIGIN_TIMEOUT", "Origin server did not respond in time", http.StatusGatewayTimeout)
+		}
+		s.logger.Error().Err(appErr).Str("route", rt.name).Str("upstream", rt.upstream.name).Str("target", member.URL.String()).Msg("Origin request failed")
+		return nil, nil, appErr
 	}
 
-	// Create cache entry with TTL
+	// Create cache entry; the caller assigns a TTL from the status policy
 	entry := &cache.Entry{
-		Body:    body,
+		URL:     request.target.RequestURI(),
+		Method:  request.method,
 		Headers: make(http.Header),
 		Status:  resp.StatusCode,
-		TTL:     s.config.CacheTTL,
+		Route:   rt.name,
 	}
 
-	// Copy response headers
+	// Copy response headers, except those meant for this hop only
 	for key, values := range resp.Header {
 		entry.Headers[key] = values
 	}
+	removeHopHeaders(entry.Headers)
+	applyHeaderRules(entry.Headers, rt.upstream.responseHeaders)
+
+	// A stream never finishes, so it is judged on its headers and handed
+	// over with the attempt's cancellation; neither ctx nor the timeouts
+	// apply to it from here. It stays in flight on its target until closed.
+	if request.stream && s.isStream(rt, resp) && detach() {
+		streaming = true
+		timeout.Stop()
+		latency := time.Since(start)
+		failed := resp.StatusCode >= http.StatusInternalServerError
+		ticket.done(latency, failed)
+		rt.upstream.health.Observe(member, failed)
+		s.adaptive.observe(latency, failed)
+		return entry, &streamBody{
+			ReadCloser: resp.Body,
+			cancel:     func() { cancel(nil) },
+			done:       func() { done(latency, failed) },
+		}, nil
+	}
+	defer resp.Body.Close()
+
+	// Read response body, separately bounded when a body timeout is set
+	if rt.upstream.bodyTimeout > 0 {
+		timer := time.AfterFunc(rt.upstream.bodyTimeout, func() { cancel(errBodyTimeout) })
+		defer timer.Stop()
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	failed := (err != nil && ctx.Err() == nil) || resp.StatusCode >= http.StatusInternalServerError
+	done(time.Since(start), failed)
+	if err != nil && !failed {
+		ticket.cancel()
+	} else {
+		ticket.done(time.Since(start), failed)
+	}
+	rt.upstream.health.Observe(member, failed)
+	s.adaptive.observe(time.Since(start), failed)
+	if err != nil {
+		appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "ORIGIN_RESPONSE_READ_FAILED", "Failed to read response from origin server", http.StatusInternalServerError)
+		if cause := context.Cause(attemptCtx); cause == errBodyTimeout || cause == errOriginTimeout || isTimeout(cause) || isTimeout(err) {
+			appErr = errors.Wrap(err, errors.ErrorTypeTimeout, "ORIGIN_TIMEOUT", "Origin server did not send the response in time", http.StatusGatewayTimeout)
+		}
+		s.logger.Error().Err(appErr).Str("route", rt.name).Str("upstream", rt.upstream.name).Str("target", member.URL.String()).Msg("Failed to read origin response")
+		return nil, nil, appErr
+	}
+	if rt.hedge != nil && !failed {
+		rt.hedge.observe(time.Since(start))
+	}
+	entry.Body = respBody
+
+	return entry, nil, nil
+}
+
+// isTimeout reports whether err is a deadline or network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return stderrors.Is(err, context.DeadlineExceeded) || (stderrors.As(err, &netErr) && netErr.Timeout())
+}
 
-	// Store in cache
-	if err := s.cache.Set(cacheKey, entry); err != nil {
-		s.logger.Error().Err(err).Str("cache_key", cacheKey).Msg("Failed to store entry in cache")
+// requestContext bounds an origin request by the deadline the client asked
+// for in the deadline header, if it is shorter than the route's upstream
+// timeout
+func (s *Server) requestContext(c *gin.Context, rt *route) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(c.Request.Context(), "request_id", c.GetString("request_id"))
+	if s.config.DeadlineHeader == "" {
+		return ctx, func() {}
+	}
+	value := c.GetHeader(s.config.DeadlineHeader)
+	if value == "" {
+		return ctx, func() {}
 	}
 
-	// Send response to client
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
+	deadline, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.ParseFloat(value, 64)
+		if convErr != nil || seconds <= 0 {
+			return ctx, func() {}
 		}
+		deadline = time.Duration(seconds * float64(time.Second))
 	}
-	c.Header("X-Cache", "MISS")
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	if deadline <= 0 || deadline > rt.upstream.timeout {
+		deadline = rt.upstream.timeout
+	}
+	return context.WithTimeout(ctx, deadline)
 }
 
 // Start starts the proxy server with graceful shutdown support
 func (s *Server) Start() error {
 	addr := s.config.Host + ":" + strconv.Itoa(s.config.Port)
-	
+
 	s.httpServer = &http.Server{
-		Addr:         addr,
-		Handler:      s.router,
-		ReadTimeout:  s.config.Timeout,
-		WriteTimeout: s.config.Timeout,
-		IdleTimeout:  60 * time.Second,
+		Addr:              addr,
+		Handler:           s.router,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
 	}
 
 	s.logger.Info().
@@ -264,28 +706,64 @@ func (s *Server) Start() error {
 		Dur("timeout", s.config.Timeout).
 		Msg("Starting HTTP server")
 
+	if s.memoryMonitor != nil {
+		s.memoryMonitor.Start()
+	}
+
+	for _, up := range s.upstreams {
+		up.health.Start()
+	}
+
+	go s.warmOnBoot()
+
 	return s.httpServer.ListenAndServe()
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info().Msg("Shutting down server")
-	
+
+	s.warmer.Close()
+
+	for _, up := range s.upstreams {
+		up.health.Stop()
+	}
+
+	if s.memoryMonitor != nil {
+		s.memoryMonitor.Stop()
+	}
+
 	if s.httpServer != nil {
-		if err := s.httpServer.Shutdown(ctx); err != nil {
+		// Hijacked connections aren't tracked by the HTTP server, so drain
+		// them separately once it has stopped taking requests
+		err := s.httpServer.Shutdown(ctx)
+		s.upgrades.shutdown(ctx)
+		if err != nil {
 			return err
 		}
 	}
-	
-	// Close cache cleanup goroutine if it implements Close()
-	if closer, ok := s.cache.(interface{ Close() }); ok {
-		closer.Close()
+
+	// Close cache cleanup goroutines for caches that implement Close()
+	for _, c := range s.caches() {
+		if closer, ok := c.(interface{ Close() }); ok {
+			closer.Close()
+		}
 	}
-	
+
 	return nil
 }
 
 // ClearCache clears all cache entries (legacy method for backwards compatibility)
 func (s *Server) ClearCache() error {
-	return s.cache.Clear()
+	return clearZones(s.zones)
+}
+
+// clearZones clears each zone's cache, stopping at the first failure
+func clearZones(zones []*zone) error {
+	for _, z := range zones {
+		if err := z.cache.Clear(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
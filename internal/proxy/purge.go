@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlePurge evicts the cached GET and HEAD responses for the requested URL
+func (s *Server) handlePurge(c *gin.Context) {
+	rt, ok := s.routeFor(c.Request.Host, c.Request.URL.Path)
+	if !ok {
+		appErr := errors.New(errors.ErrorTypeNotFound, "ROUTE_NOT_FOUND", "No route matches the request", http.StatusNotFound)
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	z := s.zoneForRoute(rt, c.Request.Host, c.Request.URL.Path)
+	purged := 0
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		cacheKey := z.cache.GenerateKey(rt.name, method, c.Request.Host, c.Request.URL.Path, c.Request.URL.RawQuery)
+		if err := z.cache.Delete(cacheKey); err == nil {
+			purged++
+		}
+	}
+
+	s.logger.Info().
+		Str("url", c.Request.URL.RequestURI()).
+		Str("route", rt.name).
+		Str("zone", z.name).
+		Int("purged", purged).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Cache purge requested")
+
+	if purged == 0 {
+		appErr := errors.New(errors.ErrorTypeNotFound, "PURGE_NOT_FOUND", "No cached entry for URL", http.StatusNotFound)
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Purged",
+		"url":       c.Request.URL.RequestURI(),
+		"purged":    purged,
+		"timestamp": time.Now(),
+	})
+}
+
+// handleBan registers a ban in every zone from the X-Ban-Expression header, or from
+// X-Ban-Url as shorthand for `req.url ~ <pattern>`
+func (s *Server) handleBan(c *gin.Context) {
+	expression := c.GetHeader("X-Ban-Expression")
+	if expression == "" && c.GetHeader("X-Ban-Url") != "" {
+		expression = "req.url ~ " + c.GetHeader("X-Ban-Url")
+	}
+
+	ban, err := cache.ParseBan(expression)
+	if err != nil {
+		appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_BAN_EXPRESSION", err.Error(), http.StatusBadRequest)
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	// Stamped once so every zone shares the same ban time and none of them
+	// writes to the ban
+	ban.CreatedAt = time.Now()
+	for _, z := range s.zones {
+		z.cache.Ban(ban)
+	}
+
+	s.logger.Info().
+		Str("expression", ban.Expression).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Cache ban added")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Ban added",
+		"expression": ban.Expression,
+		"timestamp":  ban.CreatedAt,
+	})
+}
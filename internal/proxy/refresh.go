@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cache-proxy/internal/cache"
+)
+
+// RefreshStats reports refresh-ahead activity
+type RefreshStats struct {
+	Enabled   bool    `json:"enabled"`
+	Fraction  float64 `json:"fraction"`
+	InFlight  int     `json:"in_flight"`
+	Refreshed int64   `json:"refreshed"`
+	Failed    int64   `json:"failed"`
+	Skipped   int64   `json:"skipped"`
+}
+
+// refresher proactively re-fetches hot entries before they expire so
+// popular content is never served as a miss
+type refresher struct {
+	fraction float64
+	minHits  int64
+	timeout  time.Duration
+	slots    chan struct{}
+	inFlight sync.Map
+
+	refreshed int64
+	failed    int64
+	skipped   int64
+}
+
+// newRefresher creates a refresher; a zero fraction disables it
+func newRefresher(fraction float64, minHits, concurrency int, timeout time.Duration) *refresher {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &refresher{
+		fraction: fraction,
+		minHits:  int64(minHits),
+		timeout:  timeout,
+		slots:    make(chan struct{}, concurrency),
+	}
+}
+
+// due reports whether an entry is hot and far enough into ttl, the TTL
+// currently in effect for it, to refresh
+func (r *refresher) due(entry *cache.Entry, ttl time.Duration) bool {
+	if r.fraction <= 0 || entry.TTL == 0 || entry.Pinned || entry.Frozen {
+		return false
+	}
+	if entry.Method != http.MethodGet && entry.Method != http.MethodHead {
+		return false
+	}
+	if entry.Hits() < r.minHits {
+		return false
+	}
+	return time.Since(entry.CreatedAt) >= time.Duration(float64(ttl)*r.fraction)
+}
+
+// stats returns a snapshot of refresh-ahead counters
+func (r *refresher) stats() RefreshStats {
+	return RefreshStats{
+		Enabled:   r.fraction > 0,
+		Fraction:  r.fraction,
+		InFlight:  len(r.slots),
+		Refreshed: atomic.LoadInt64(&r.refreshed),
+		Failed:    atomic.LoadInt64(&r.failed),
+		Skipped:   atomic.LoadInt64(&r.skipped),
+	}
+}
+
+// maybeRefresh schedules a background refresh of a hot entry nearing expiry.
+// Refreshes are deduplicated per key and skipped when all slots are busy.
+func (s *Server) maybeRefresh(z *zone, cacheKey string, entry *cache.Entry) {
+	r := s.refresher
+	if !r.due(entry, s.adaptive.extend(entry.TTL)) {
+		return
+	}
+
+	if _, busy := r.inFlight.LoadOrStore(cacheKey, struct{}{}); busy {
+		return
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		r.inFlight.Delete(cacheKey)
+		atomic.AddInt64(&r.skipped, 1)
+		return
+	}
+
+	go func() {
+		defer func() {
+			<-r.slots
+			r.inFlight.Delete(cacheKey)
+		}()
+		s.refresh(z, cacheKey, entry)
+	}()
+}
+
+// refresh re-fetches an entry from the origin and replaces it in its zone
+func (s *Server) refresh(z *zone, cacheKey string, entry *cache.Entry) {
+	target, err := url.Parse(entry.URL)
+	if err != nil {
+		atomic.AddInt64(&s.refresher.failed, 1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.refresher.timeout)
+	defer cancel()
+
+	rt, exists := s.routeByName(entry.Route)
+	if !exists {
+		atomic.AddInt64(&s.refresher.failed, 1)
+		return
+	}
+
+	fresh, _, appErr := s.fetchFromOrigin(ctx, rt, cacheKey, &originRequest{
+		method: entry.Method,
+		host:   entry.Host,
+		target: target,
+	})
+	if appErr != nil {
+		atomic.AddInt64(&s.refresher.failed, 1)
+		return
+	}
+
+	// Keep serving the current entry if the new response isn't cacheable; it will expire normally
+	ttl, cacheable := policyFor(z, rt).TTLFor(fresh.Status)
+	if !cacheable {
+		atomic.AddInt64(&s.refresher.failed, 1)
+		return
+	}
+	fresh.TTL = ttl
+	fresh.Host = entry.Host
+
+	if err := z.cache.Set(cacheKey, fresh); err == cache.ErrFrozen {
+		// The entry was rolled back and frozen while the refresh was in flight
+		atomic.AddInt64(&s.refresher.skipped, 1)
+		return
+	} else if err != nil {
+		s.logger.Error().Err(err).Str("zone", z.name).Str("cache_key", cacheKey).Msg("Failed to store refreshed entry in cache")
+		atomic.AddInt64(&s.refresher.failed, 1)
+		return
+	}
+
+	atomic.AddInt64(&s.refresher.refreshed, 1)
+	s.logger.Debug().
+		Str("cache_key", cacheKey).
+		Str("url", entry.URL).
+		Int64("hits", entry.Hits()).
+		Msg("Refreshed hot cache entry ahead of expiry")
+}
@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// retryBudgetBuckets is how many one-second slices the retry budget
+	// window is divided into
+	retryBudgetBuckets = 10
+)
+
+// idempotentMethods can be sent again without changing the outcome
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retryPolicy decides whether and when a failed origin request is retried
+type retryPolicy struct {
+	attempts   int // retries after the first attempt
+	backoff    time.Duration
+	maxBackoff time.Duration
+	retryOn    map[int]bool
+}
+
+// newRetryPolicy creates a policy; zero attempts disables retries
+func newRetryPolicy(attempts int, backoff, maxBackoff time.Duration, statuses []int) *retryPolicy {
+	retryOn := make(map[int]bool, len(statuses))
+	for _, status := range statuses {
+		retryOn[status] = true
+	}
+	return &retryPolicy{attempts: attempts, backoff: backoff, maxBackoff: maxBackoff, retryOn: retryOn}
+}
+
+// allows reports whether requests with method may be retried at all
+func (p *retryPolicy) allows(method string) bool {
+	return p.attempts > 0 && idempotentMethods[method]
+}
+
+// delay returns how long to wait before retry number attempt (starting at 1):
+// the origin's Retry-After when it sent one, otherwise exponential backoff
+// with jitter. It returns false when Retry-After asks for longer than the
+// max backoff, in which case the response is passed on instead.
+func (p *retryPolicy) delay(attempt int, retryAfter string) (time.Duration, bool) {
+	if retryAfter != "" {
+		if wait, ok := parseRetryAfter(retryAfter); ok {
+			return wait, wait <= p.maxBackoff
+		}
+	}
+
+	backoff := p.backoff << (attempt - 1)
+	if backoff > p.maxBackoff || backoff <= 0 {
+		backoff = p.maxBackoff
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	// Equal jitter: half the backoff is fixed, the rest random, so retries
+	// from many clients spread out without collapsing to zero
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1)), true
+}
+
+// fitsDeadline reports whether waiting delay still leaves time for another
+// attempt before ctx or deadline, whichever comes first, runs out
+func fitsDeadline(ctx context.Context, deadline time.Time, delay time.Duration) bool {
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	return deadline.IsZero() || delay < time.Until(deadline)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// RetryStats reports an upstream's retries and its retry budget
+type RetryStats struct {
+	Requests  int64 `json:"requests"`  // first attempts in the budget window
+	Retries   int64 `json:"retries"`   // retries in the budget window
+	Allowed   int64 `json:"allowed"`   // retries the window currently allows
+	Total     int64 `json:"total"`     // retries since start
+	Exhausted int64 `json:"exhausted"` // retries refused by the budget since start
+}
+
+// retryBucket counts requests and retries for one second of the window
+type retryBucket struct {
+	second   int64
+	requests int64
+	retries  int64
+}
+
+// retryBudget caps retries to a fraction of recent requests, plus a small
+// floor, so retries can't multiply the load on an origin that is down
+type retryBudget struct {
+	ratio float64
+	min   int64
+
+	mutex     sync.Mutex
+	buckets   [retryBudgetBuckets]retryBucket
+	total     int64
+	exhausted int64
+}
+
+// newRetryBudget creates a budget allowing ratio retries per request over
+// the window, and at least min retries
+func newRetryBudget(ratio float64, min int) *retryBudget {
+	return &retryBudget{ratio: ratio, min: int64(min)}
+}
+
+// request records a first attempt
+func (b *retryBudget) request() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.bucket(time.Now()).requests++
+}
+
+// withdraw reports whether a retry fits in the budget and records it if so
+func (b *retryBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	requests, retries := b.totals(now)
+	if retries >= b.allowed(requests) {
+		b.exhausted++
+		return false
+	}
+	b.bucket(now).retries++
+	b.total++
+	return true
+}
+
+// stats returns a snapshot of the budget
+func (b *retryBudget) stats() RetryStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	requests, retries := b.totals(time.Now())
+	return RetryStats{
+		Requests:  requests,
+		Retries:   retries,
+		Allowed:   b.allowed(requests),
+		Total:     b.total,
+		Exhausted: b.exhausted,
+	}
+}
+
+// allowed returns how many retries the window allows for requests
+func (b *retryBudget) allowed(requests int64) int64 {
+	return b.min + int64(b.ratio*float64(requests))
+}
+
+// bucket returns the bucket for now, resetting it if it is from an earlier
+// pass of the window. Must be called with the mutex held.
+func (b *retryBudget) bucket(now time.Time) *retryBucket {
+	second := now.Unix()
+	bucket := &b.buckets[second%retryBudgetBuckets]
+	if bucket.second != second {
+		*bucket = retryBucket{second: second}
+	}
+	return bucket
+}
+
+// totals sums the buckets still inside the window. Must be called with the
+// mutex held.
+func (b *retryBudget) totals(now time.Time) (int64, int64) {
+	cutoff := now.Unix() - retryBudgetBuckets
+	var requests, retries int64
+	for _, bucket := range b.buckets {
+		if bucket.second > cutoff {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	return requests, retries
+}
+
+// retryStats returns every upstream's retry budget
+func (s *Server) retryStats() map[string]RetryStats {
+	stats := make(map[string]RetryStats, len(s.upstreams))
+	for _, up := range s.upstreams {
+		stats[up.name] = up.retries.stats()
+	}
+	return stats
+}
@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cache-proxy/internal/config"
+)
+
+// TestParseRetryAfter covers the seconds and HTTP date forms
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"seconds", "3", 3 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, false},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{"garbage", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(future); !ok || got <= 8*time.Second || got > 10*time.Second {
+		t.Errorf("parseRetryAfter(future date) = %v, %v; want about 10s", got, ok)
+	}
+}
+
+// TestRetryDelay checks that backoff doubles with equal jitter, is capped,
+// and that Retry-After overrides it unless it is longer than the cap
+func TestRetryDelay(t *testing.T) {
+	policy := newRetryPolicy(5, 100*time.Millisecond, time.Second, []int{503})
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter string
+		min, max   time.Duration
+		wantOK     bool
+	}{
+		{"first retry", 1, "", 50 * time.Millisecond, 100 * time.Millisecond, true},
+		{"second retry doubles", 2, "", 100 * time.Millisecond, 200 * time.Millisecond, true},
+		{"third retry doubles again", 3, "", 200 * time.Millisecond, 400 * time.Millisecond, true},
+		{"capped at max backoff", 10, "", 500 * time.Millisecond, time.Second, true},
+		{"shift overflow is capped", 80, "", 500 * time.Millisecond, time.Second, true},
+		{"retry-after within cap", 1, "1", time.Second, time.Second, true},
+		{"retry-after beyond cap", 1, "5", 5 * time.Second, 5 * time.Second, false},
+		{"invalid retry-after falls back", 1, "later", 50 * time.Millisecond, 100 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				got, ok := policy.delay(tt.attempt, tt.retryAfter)
+				if ok != tt.wantOK || got < tt.min || got > tt.max {
+					t.Fatalf("delay(%d, %q) = %v, %v; want %v..%v, %v", tt.attempt, tt.retryAfter, got, ok, tt.min, tt.max, tt.wantOK)
+				}
+			}
+		})
+	}
+}
+
+// TestFitsDeadline checks that a delay must end before the earlier of the
+// context deadline and the upstream deadline
+func TestFitsDeadline(t *testing.T) {
+	background := context.Background()
+	short, cancel := context.WithTimeout(background, 100*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		deadline time.Duration
+		delay    time.Duration
+		want     bool
+	}{
+		{"no deadlines", background, 0, time.Hour, true},
+		{"within upstream deadline", background, time.Second, 10 * time.Millisecond, true},
+		{"beyond upstream deadline", background, time.Second, 2 * time.Second, false},
+		{"beyond earlier context deadline", short, time.Second, 500 * time.Millisecond, false},
+		{"within context deadline", short, 0, 10 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deadline time.Time
+			if tt.deadline > 0 {
+				deadline = time.Now().Add(tt.deadline)
+			}
+			if got := fitsDeadline(tt.ctx, deadline, tt.delay); got != tt.want {
+				t.Errorf("fitsDeadline(%v) = %v, want %v", tt.delay, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRetrySkippedPastDeadline checks that a Retry-After longer than the
+// client's remaining deadline passes the response on instead of waiting
+func TestRetrySkippedPastDeadline(t *testing.T) {
+	var hits atomic.Int32
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	s := newTestServer(t, origin, func(cfg *config.Config) {
+		cfg.RetryAttempts = 2
+		cfg.RetryMaxBackoff = 5 * time.Second
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/busy", nil)
+	req.Header.Set("X-Request-Timeout", "500ms")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	s.router.ServeHTTP(rec, req)
+	elapsed := time.Since(start)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("origin hits = %d, want 1", hits.Load())
+	}
+	if elapsed > 250*time.Millisecond {
+		t.Errorf("request took %v, want it passed on without waiting", elapsed)
+	}
+}
+
+// TestRetryPolicyAllows checks that only idempotent methods are retried
+func TestRetryPolicyAllows(t *testing.T) {
+	policy := newRetryPolicy(2, time.Millisecond, time.Second, nil)
+	for method, want := range map[string]bool{
+		http.MethodGet:    true,
+		http.MethodPut:    true,
+		http.MethodDelete: true,
+		http.MethodPost:   false,
+		http.MethodPatch:  false,
+	} {
+		if got := policy.allows(method); got != want {
+			t.Errorf("allows(%s) = %v, want %v", method, got, want)
+		}
+	}
+	if newRetryPolicy(0, time.Millisecond, time.Second, nil).allows(http.MethodGet) {
+		t.Error("zero attempts should disable retries")
+	}
+}
+
+// TestRetryBudget checks that retries are capped at the minimum plus the
+// ratio of requests
+func TestRetryBudget(t *testing.T) {
+	budget := newRetryBudget(0.5, 1)
+	for i := 0; i < 4; i++ {
+		budget.request()
+	}
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if budget.withdraw() {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d retries, want 3 (1 minimum + 0.5 x 4 requests)", allowed)
+	}
+	if stats := budget.stats(); stats.Exhausted != 7 {
+		t.Errorf("exhausted = %d, want 7", stats.Exhausted)
+	}
+}
+
+// TestRetriesShareUpstreamTimeout checks that the upstream timeout bounds
+// all attempts together, and caps a longer client deadline
+func TestRetriesShareUpstreamTimeout(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	s := newTestServer(t, origin, func(cfg *config.Config) {
+		cfg.Timeout = 250 * time.Millisecond
+		cfg.RetryAttempts = 3
+		cfg.RetryBackoff = time.Millisecond
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("X-Request-Timeout", "10s")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	s.router.ServeHTTP(rec, req)
+	elapsed := time.Since(start)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", rec.Code)
+	}
+	if elapsed > 400*time.Millisecond {
+		t.Errorf("request took %v, want about the 250ms upstream timeout", elapsed)
+	}
+}
@@ -0,0 +1,304 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cache-proxy/internal/balancer"
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/config"
+	"cache-proxy/internal/health"
+	"cache-proxy/internal/logger"
+)
+
+// defaultRouteName names the upstream and route built from --origin
+const defaultRouteName = "default"
+
+// upstream is a named pool of origin servers with its own client, cache
+// settings and header rules
+type upstream struct {
+	name            string
+	pool            *balancer.Pool
+	health          *balancer.Checker
+	breaker         *circuitBreaker
+	retries         *retryBudget
+	client          *http.Client
+	timeout         time.Duration // bounds a request until its body is read or streamed
+	bodyTimeout     time.Duration // 0 leaves the body to the client timeout
+	zone            string
+	cacheTTL        time.Duration
+	noCache         bool
+	preserveHost    bool // send the client's Host instead of the origin's
+	requestHeaders  config.HeaderRules
+	responseHeaders config.HeaderRules
+}
+
+// route sends requests matching its hosts and path prefixes to an upstream
+type route struct {
+	name        string
+	upstream    *upstream
+	hosts       []string
+	prefixes    []string
+	stripPrefix bool
+	hedge       *hedger // nil unless the route hedges
+	stream      bool    // relay every response unbuffered and uncached
+}
+
+// newUpstream creates an upstream whose client uses a copy of base with the
+// upstream's connection timeouts. Zero timeouts fall back to the proxy-wide
+// ones.
+func newUpstream(uc config.UpstreamConfig, cfg *config.Config, base *http.Transport, log logger.Logger) (*upstream, error) {
+	targets := make([]balancer.TargetConfig, 0, len(uc.Targets)+1)
+	if uc.URL != "" {
+		targets = append(targets, balancer.TargetConfig{URL: uc.URL})
+	}
+	for _, tc := range uc.Targets {
+		targets = append(targets, balancer.TargetConfig{URL: tc.URL, Weight: tc.Weight})
+	}
+	pool, err := balancer.NewPool(uc.Strategy, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := base.Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   orDefault(uc.DialTimeout, cfg.DialTimeout),
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = orDefault(uc.TLSHandshakeTimeout, cfg.TLSHandshakeTimeout)
+	transport.ResponseHeaderTimeout = orDefault(uc.ResponseHeaderTimeout, cfg.ResponseHeaderTimeout)
+
+	// Probes must see the target's own answer, not wherever it redirects to
+	probeClient := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	checker := balancer.NewChecker(uc.Name, pool, balancer.HealthCheck{
+		Path:               uc.HealthCheck.Path,
+		Interval:           time.Duration(uc.HealthCheck.Interval),
+		Timeout:            time.Duration(uc.HealthCheck.Timeout),
+		ExpectedStatus:     uc.HealthCheck.ExpectedStatus,
+		HealthyThreshold:   uc.HealthCheck.HealthyThreshold,
+		UnhealthyThreshold: uc.HealthCheck.UnhealthyThreshold,
+	}, balancer.OutlierDetection{
+		ConsecutiveErrors: uc.OutlierDetection.ConsecutiveErrors,
+		EjectionTime:      time.Duration(uc.OutlierDetection.EjectionTime),
+	}, probeClient, log)
+
+	hostHeader := uc.HostHeader
+	if hostHeader == "" {
+		hostHeader = cfg.HostHeader
+	}
+
+	return &upstream{
+		name:            uc.Name,
+		pool:            pool,
+		health:          checker,
+		retries:         newRetryBudget(cfg.RetryBudgetRatio, cfg.RetryBudgetMin),
+		breaker:         newCircuitBreaker(uc.Name, cfg.BreakerErrorRate, cfg.BreakerLatency, cfg.BreakerMinRequests, cfg.BreakerWindow, cfg.BreakerCooldown, cfg.BreakerHalfOpenRequests, log),
+		client:          &http.Client{Transport: transport},
+		timeout:         orDefault(uc.Timeout, cfg.Timeout),
+		bodyTimeout:     orDefault(uc.BodyTimeout, cfg.BodyTimeout),
+		zone:            uc.Zone,
+		cacheTTL:        time.Duration(uc.CacheTTL),
+		noCache:         uc.NoCache,
+		preserveHost:    hostHeader == "preserve",
+		requestHeaders:  uc.RequestHeaders,
+		responseHeaders: uc.ResponseHeaders,
+	}, nil
+}
+
+// newRoutes builds the upstreams and the routing table from configured
+// routes, followed by a catch-all default route when an origin is configured
+func newRoutes(cfg *config.Config, transport *http.Transport, log logger.Logger) ([]*route, []*upstream, error) {
+	configs := cfg.Upstreams
+	if cfg.Origin != "" {
+		configs = append(configs[:len(configs):len(configs)], config.UpstreamConfig{Name: defaultRouteName, URL: cfg.Origin})
+	}
+
+	upstreams := make(map[string]*upstream, len(configs))
+	ordered := make([]*upstream, 0, len(configs))
+	for _, uc := range configs {
+		up, err := newUpstream(uc, cfg, transport, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		upstreams[up.name] = up
+		ordered = append(ordered, up)
+	}
+
+	routes := make([]*route, 0, len(cfg.Routes)+1)
+	for _, rc := range cfg.Routes {
+		rt := &route{
+			name:        rc.Name,
+			upstream:    upstreams[rc.Upstream],
+			prefixes:    rc.PathPrefixes,
+			stripPrefix: rc.StripPrefix,
+			hedge:       newHedger(rc.Hedge),
+			stream:      rc.Stream,
+		}
+		for _, host := range rc.Hosts {
+			rt.hosts = append(rt.hosts, strings.ToLower(host))
+		}
+		routes = append(routes, rt)
+	}
+	if up, exists := upstreams[defaultRouteName]; exists {
+		routes = append(routes, &route{name: defaultRouteName, upstream: up})
+	}
+	return routes, ordered, nil
+}
+
+// orDefault returns an upstream's setting, or the proxy-wide one when unset
+func orDefault(setting config.Duration, fallback time.Duration) time.Duration {
+	if setting > 0 {
+		return time.Duration(setting)
+	}
+	return fallback
+}
+
+// match reports whether the route accepts a request for host and path
+func (r *route) match(host, requestPath string) bool {
+	if len(r.hosts) > 0 && !hostMatches(r.hosts, host) {
+		return false
+	}
+	_, ok := r.prefixFor(requestPath)
+	return ok
+}
+
+// prefixFor returns the longest of the route's prefixes that requestPath
+// starts with, and whether the path matches at all
+func (r *route) prefixFor(requestPath string) (string, bool) {
+	if len(r.prefixes) == 0 {
+		return "", true
+	}
+
+	matched, found := "", false
+	for _, prefix := range r.prefixes {
+		if pathHasPrefix(requestPath, prefix) && len(prefix) >= len(matched) {
+			matched, found = prefix, true
+		}
+	}
+	return matched, found
+}
+
+// pathHasPrefix reports whether prefix covers requestPath on a segment
+// boundary, so /api matches /api and /api/users but not /apiary
+func pathHasPrefix(requestPath, prefix string) bool {
+	if !strings.HasPrefix(requestPath, prefix) {
+		return false
+	}
+	return len(requestPath) == len(prefix) || strings.HasSuffix(prefix, "/") || requestPath[len(prefix)] == '/'
+}
+
+// upstreamURL returns the URL on target for a client request path and query
+func (r *route) upstreamURL(target *balancer.Target, requestPath, rawQuery string) *url.URL {
+	if r.stripPrefix {
+		if prefix, _ := r.prefixFor(requestPath); prefix != "" {
+			requestPath = "/" + strings.TrimPrefix(strings.TrimPrefix(requestPath, prefix), "/")
+		}
+	}
+
+	upstreamURL := *target.URL
+	upstreamURL.Path = strings.TrimSuffix(upstreamURL.Path, "/") + requestPath
+	upstreamURL.RawPath = ""
+	upstreamURL.RawQuery = rawQuery
+	return &upstreamURL
+}
+
+// routeFor returns the first route matching the request
+func (s *Server) routeFor(host, requestPath string) (*route, bool) {
+	for _, rt := range s.routes {
+		if rt.match(host, requestPath) {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+// routeByName returns the route with the given name
+func (s *Server) routeByName(name string) (*route, bool) {
+	for _, rt := range s.routes {
+		if rt.name == name {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+// zoneForRoute returns the zone pinned by the route's upstream, or the zone
+// whose rules match the request
+func (s *Server) zoneForRoute(rt *route, host, requestPath string) *zone {
+	if rt.upstream.zone != "" {
+		if z, exists := s.zoneByName(rt.upstream.zone); exists {
+			return z
+		}
+	}
+	return s.zoneFor(host, requestPath)
+}
+
+// policyFor returns the zone's status policy with the upstream's TTL override
+func policyFor(z *zone, rt *route) cache.StatusPolicy {
+	policy := z.policy
+	if rt.upstream.cacheTTL > 0 {
+		policy.SuccessTTL = rt.upstream.cacheTTL
+	}
+	return policy
+}
+
+// applyHeaderRules removes, sets and adds headers according to rules
+func applyHeaderRules(header http.Header, rules config.HeaderRules) {
+	for _, name := range rules.Remove {
+		header.Del(name)
+	}
+	for name, value := range rules.Set {
+		header.Set(name, value)
+	}
+	for name, value := range rules.Add {
+		header.Add(name, value)
+	}
+}
+
+// hostMatches reports whether host, ignoring any port, is one of hosts.
+// Entries like "*.example.com" match any subdomain.
+func hostMatches(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, candidate := range hosts {
+		if candidate == host {
+			return true
+		}
+		if strings.HasPrefix(candidate, "*.") && strings.HasSuffix(host, candidate[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamStats returns pool and per-target stats for every upstream
+func (s *Server) upstreamStats() map[string]balancer.PoolStats {
+	stats := make(map[string]balancer.PoolStats, len(s.upstreams))
+	for _, up := range s.upstreams {
+		stats[up.name] = up.pool.Stats()
+	}
+	return stats
+}
+
+// upstreamHealth reports the upstreams as degraded while any target is out
+// of rotation; the proxy can still serve cached responses for them
+func (s *Server) upstreamHealth() health.ComponentStatus {
+	stats := s.upstreamStats()
+	status := "healthy"
+	for _, pool := range stats {
+		if pool.Healthy < len(pool.Targets) {
+			status = "degraded"
+		}
+	}
+	return health.ComponentStatus{Status: status, Details: stats}
+}
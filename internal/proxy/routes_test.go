@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"testing"
+
+	"cache-proxy/internal/balancer"
+)
+
+// TestRouteMatch covers host matching, including wildcards and ports, and
+// segment-aware path prefixes
+func TestRouteMatch(t *testing.T) {
+	rt := &route{
+		hosts:    []string{"example.com", "*.example.org"},
+		prefixes: []string{"/api", "/static/"},
+	}
+
+	tests := []struct {
+		name string
+		host string
+		path string
+		want bool
+	}{
+		{"exact host and prefix", "example.com", "/api", true},
+		{"prefix segment", "example.com", "/api/users", true},
+		{"prefix without boundary", "example.com", "/apiary", false},
+		{"prefix ending in slash", "example.com", "/static/app.js", true},
+		{"host with port", "example.com:8080", "/api", true},
+		{"host case", "EXAMPLE.com", "/api", true},
+		{"wildcard subdomain", "cdn.example.org", "/api", true},
+		{"wildcard nested subdomain", "a.b.example.org", "/api", true},
+		{"wildcard excludes apex", "example.org", "/api", false},
+		{"other host", "example.net", "/api", false},
+		{"unmatched path", "example.com", "/other", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rt.match(tt.host, tt.path); got != tt.want {
+				t.Errorf("match(%q, %q) = %v, want %v", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRouteMatchAnyHost checks that a route without hosts or prefixes
+// accepts everything
+func TestRouteMatchAnyHost(t *testing.T) {
+	rt := &route{}
+	if !rt.match("anything.test", "/any/path") {
+		t.Error("route without hosts or prefixes should match any request")
+	}
+}
+
+// TestUpstreamURL covers prefix stripping, the longest prefix winning and
+// target base paths
+func TestUpstreamURL(t *testing.T) {
+	pool, err := balancer.NewPool("round_robin", []balancer.TargetConfig{{URL: "http://origin.test/base/"}})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	target := pool.Targets()[0]
+
+	tests := []struct {
+		name  string
+		strip bool
+		path  string
+		query string
+		want  string
+	}{
+		{"no strip", false, "/api/users", "", "http://origin.test/base/api/users"},
+		{"strip prefix", true, "/api/users", "a=1", "http://origin.test/base/users?a=1"},
+		{"strip exact prefix", true, "/api", "", "http://origin.test/base/"},
+		{"longest prefix wins", true, "/api/v2/users", "", "http://origin.test/base/users"},
+		{"no boundary is not stripped", true, "/apiary", "", "http://origin.test/base/apiary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &route{prefixes: []string{"/api", "/api/v2"}, stripPrefix: tt.strip}
+			if got := rt.upstreamURL(target, tt.path, tt.query).String(); got != tt.want {
+				t.Errorf("upstreamURL(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestZoneMatch covers zone hosts, segment-aware path prefixes and
+// extensions
+func TestZoneMatch(t *testing.T) {
+	z := &zone{
+		hosts:      []string{"*.example.com"},
+		prefixes:   []string{"/api", "/assets/"},
+		extensions: []string{".css"},
+	}
+
+	tests := []struct {
+		name string
+		host string
+		path string
+		want bool
+	}{
+		{"prefix", "www.example.com", "/api", true},
+		{"prefix segment", "www.example.com", "/api/users", true},
+		{"prefix without boundary", "www.example.com", "/apiary", false},
+		{"prefix ending in slash", "www.example.com", "/assets/app.js", true},
+		{"extension", "www.example.com", "/styles/site.CSS", true},
+		{"other path", "www.example.com", "/about", false},
+		{"other host", "example.net", "/api", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := z.matches(tt.host, tt.path); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, want %v", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/config"
+	"cache-proxy/internal/logger"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestServer creates a proxy in front of origin with the default
+// configuration, adjusted by configure when it is not nil
+func newTestServer(t *testing.T, origin http.Handler, configure func(*config.Config)) *Server {
+	t.Helper()
+	upstream := httptest.NewServer(origin)
+	t.Cleanup(upstream.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Port = 8080
+	cfg.Origin = upstream.URL
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cacheInstance := cache.New(cache.Config{
+		MaxSize:         cfg.CacheSize,
+		DefaultTTL:      cfg.CacheTTL,
+		CleanupInterval: cfg.CleanupInterval,
+		Admission:       cfg.CacheAdmission,
+		Versions:        cfg.CacheVersions,
+		Eviction:        cfg.CacheEviction,
+		StaleGrace:      cfg.StaleGrace,
+	})
+	server, err := New(cfg, cacheInstance, logger.NewWithLevel(zerolog.Disabled))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+	return server
+}
+
+// serve sends a request through the proxy's router from a loopback client
+// and returns the recorded response
+func serve(s *Server, method, host, target, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Host = host
+	req.RemoteAddr = "127.0.0.1:40000"
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
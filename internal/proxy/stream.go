@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cache-proxy/internal/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamBufferSize is the most read from an origin stream before flushing
+const streamBufferSize = 32 * 1024
+
+// streamBody is an origin response body handed to the caller unread; closing
+// it also ends the origin request and releases the target's in-flight slot
+type streamBody struct {
+	io.ReadCloser
+	cancel func()
+	done   func()
+	once   sync.Once
+}
+
+// Close ends the origin request and closes the body
+func (b *streamBody) Close() error {
+	b.once.Do(func() {
+		b.cancel()
+		b.done()
+	})
+	return b.ReadCloser.Close()
+}
+
+// isStream reports whether resp should be streamed to the client rather
+// than read whole: every response on a streaming route, and otherwise
+// responses with one of the configured streaming content types
+func (s *Server) isStream(rt *route, resp *http.Response) bool {
+	if rt.stream {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, streamType := range s.config.StreamContentTypes {
+		if strings.EqualFold(strings.TrimSpace(streamType), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamResponse relays a streaming origin response to the client, flushing
+// each read as it arrives, until either side closes. It is never cached.
+func (s *Server) streamResponse(c *gin.Context, rt *route, entry *cache.Entry, stream io.ReadCloser) {
+	defer stream.Close()
+
+	// A client that goes away mid-stream would otherwise only be noticed on
+	// the next write, which may be a long time coming
+	stop := context.AfterFunc(c.Request.Context(), func() { stream.Close() })
+	defer stop()
+
+	// The server's write timeout is meant for whole responses; a stream may
+	// legitimately run for much longer
+	controller := http.NewResponseController(c.Writer)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Debug().Err(err).Str("route", rt.name).Msg("Failed to clear write deadline for stream")
+	}
+
+	for key, values := range entry.Headers {
+		for _, value := range values {
+			c.Header(key, value)
+		}
+	}
+	c.Header("X-Cache", "BYPASS")
+	c.Status(entry.Status)
+	c.Writer.WriteHeaderNow()
+
+	// gin's writer panics when flushed over a writer that can't flush, such
+	// as the warmer's; the stream is then simply copied
+	flush := func() {}
+	if canFlush(c.Writer) {
+		flush = func() { controller.Flush() }
+	}
+	flush()
+
+	s.logger.Debug().
+		Str("route", rt.name).
+		Str("url", entry.URL).
+		Str("content_type", entry.Headers.Get("Content-Type")).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Streaming origin response")
+
+	buf := make([]byte, streamBufferSize)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			flush()
+		}
+		if err != nil {
+			if err != io.EOF && c.Request.Context().Err() == nil {
+				s.logger.Warn().Err(err).Str("route", rt.name).Str("url", entry.URL).Msg("Origin stream ended with an error")
+			}
+			return
+		}
+	}
+}
+
+// canFlush reports whether the writer at the bottom of w's wrappers can flush
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			_, ok := w.(http.Flusher)
+			return ok
+		}
+		w = unwrapper.Unwrap()
+	}
+}
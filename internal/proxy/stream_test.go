@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestStreamHoldsInFlight checks that a streamed response keeps its target's
+// in-flight count until the body is done, not just until its headers arrive
+func TestStreamHoldsInFlight(t *testing.T) {
+	sent := make(chan struct{})
+	finish := make(chan struct{})
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: one\n\n"))
+		w.(http.Flusher).Flush()
+		close(sent)
+		<-finish
+	})
+	s := newTestServer(t, origin, nil)
+	target := s.upstreams[0].pool.Targets()[0]
+
+	served := make(chan struct{})
+	go func() {
+		serve(s, http.MethodGet, "site.test", "/events", "")
+		close(served)
+	}()
+
+	<-sent
+	deadline := time.Now().Add(time.Second)
+	for target.InFlight() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := target.InFlight(); got != 1 {
+		t.Errorf("in flight while streaming = %d, want 1", got)
+	}
+
+	close(finish)
+	<-served
+	if got := target.InFlight(); got != 0 {
+		t.Errorf("in flight after the stream ended = %d, want 0", got)
+	}
+}
@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cache-proxy/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpgradeStats reports connections switched to another protocol, such as
+// WebSocket, and spliced through to an origin
+type UpgradeStats struct {
+	Active      int           `json:"active"`
+	Total       int64         `json:"total"`
+	Failed      int64         `json:"failed"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+}
+
+// isUpgrade reports whether r asks to switch protocols
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(textproto.TrimString(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splice is a client connection joined to an origin connection
+type splice struct {
+	client     net.Conn
+	backend    io.ReadWriteCloser
+	lastActive atomic.Int64
+	once       sync.Once
+}
+
+// touch records traffic on the connection
+func (p *splice) touch() {
+	p.lastActive.Store(time.Now().UnixNano())
+}
+
+// idle returns how long the connection has gone without traffic
+func (p *splice) idle() time.Duration {
+	return time.Since(time.Unix(0, p.lastActive.Load()))
+}
+
+// close closes both connections, which ends both copies
+func (p *splice) close() {
+	p.once.Do(func() {
+		p.client.Close()
+		p.backend.Close()
+	})
+}
+
+// activityWriter touches the splice on every write
+type activityWriter struct {
+	w io.Writer
+	p *splice
+}
+
+func (a activityWriter) Write(b []byte) (int, error) {
+	a.p.touch()
+	return a.w.Write(b)
+}
+
+// upgradeTracker tracks upgraded connections so they can be counted, closed
+// when idle and drained on shutdown
+type upgradeTracker struct {
+	idleTimeout time.Duration
+	wg          sync.WaitGroup
+
+	mutex   sync.Mutex
+	active  map[*splice]struct{}
+	closing bool
+	total   int64
+	failed  int64
+}
+
+// newUpgradeTracker creates a tracker; a zero idle timeout keeps idle
+// connections open
+func newUpgradeTracker(idleTimeout time.Duration) *upgradeTracker {
+	return &upgradeTracker{
+		idleTimeout: idleTimeout,
+		active:      make(map[*splice]struct{}),
+	}
+}
+
+// add registers p, or returns false once shutdown has begun
+func (t *upgradeTracker) add(p *splice) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closing {
+		return false
+	}
+	t.active[p] = struct{}{}
+	t.total++
+	t.wg.Add(1)
+	return true
+}
+
+// remove unregisters p
+func (t *upgradeTracker) remove(p *splice) {
+	t.mutex.Lock()
+	delete(t.active, p)
+	t.mutex.Unlock()
+	t.wg.Done()
+}
+
+// fail counts an upgrade that could not be completed
+func (t *upgradeTracker) fail() {
+	t.mutex.Lock()
+	t.failed++
+	t.mutex.Unlock()
+}
+
+// run copies between the connections in both directions until either side
+// closes or the connection goes idle. Reads from the client go through
+// clientReader, which holds anything buffered before the hijack.
+func (t *upgradeTracker) run(p *splice, clientReader io.Reader) {
+	p.touch()
+	if !t.add(p) {
+		p.close()
+		return
+	}
+	defer t.remove(p)
+	defer p.close()
+
+	if t.idleTimeout > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go t.watchIdle(p, stop)
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(activityWriter{w: p.backend, p: p}, clientReader)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(activityWriter{w: p.client, p: p}, p.backend)
+		errc <- err
+	}()
+
+	// Once one side is done, closing both ends the other copy
+	<-errc
+	p.close()
+	<-errc
+}
+
+// watchIdle closes p once it has gone the idle timeout without traffic
+func (t *upgradeTracker) watchIdle(p *splice, stop chan struct{}) {
+	interval := t.idleTimeout / 4
+	if interval <= 0 {
+		interval = t.idleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if p.idle() >= t.idleTimeout {
+				p.close()
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// shutdown refuses new upgrades and waits for active ones to finish until
+// ctx is done, then closes the rest
+func (t *upgradeTracker) shutdown(ctx context.Context) {
+	t.mutex.Lock()
+	t.closing = true
+	t.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return
+	case <-ctx.Done():
+	}
+
+	t.mutex.Lock()
+	for p := range t.active {
+		p.close()
+	}
+	t.mutex.Unlock()
+	<-drained
+}
+
+// stats returns a snapshot of the tracker
+func (t *upgradeTracker) stats() UpgradeStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return UpgradeStats{
+		Active:      len(t.active),
+		Total:       t.total,
+		Failed:      t.failed,
+		IdleTimeout: t.idleTimeout,
+	}
+}
+
+// handleUpgrade passes an Upgrade request, such as a WebSocket handshake,
+// to the route's upstream. When the origin switches protocols the client
+// connection is hijacked and spliced to the origin's; otherwise the
+// origin's answer is passed on. Neither is cached.
+func (s *Server) handleUpgrade(c *gin.Context, rt *route) {
+	member := rt.upstream.pool.Pick(c.Request.Host + c.Request.URL.Path)
+	if member == nil {
+		s.upgrades.fail()
+		appErr := errors.New(errors.ErrorTypeNetwork, "NO_HEALTHY_UPSTREAM", "No healthy upstream server is available", http.StatusServiceUnavailable)
+		s.logger.Error().Err(appErr).Str("route", rt.name).Str("upstream", rt.upstream.name).Msg("No healthy upstream target")
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	// Forward the upgrade itself, which hop-by-hop stripping removed
+	protocol := c.Request.Header.Get("Upgrade")
+	header := s.outgoingHeader(c)
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", protocol)
+	applyHeaderRules(header, rt.upstream.requestHeaders)
+
+	// The upstream timeout bounds only the handshake; the client's request
+	// context ends with the hijack, so the connection must not depend on it
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+	defer cancel()
+	handshake := time.AfterFunc(rt.upstream.timeout, cancel)
+
+	originURL := rt.upstreamURL(member, c.Request.URL.Path, c.Request.URL.RawQuery)
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, originURL.String(), nil)
+	if err != nil {
+		handshake.Stop()
+		s.upgrades.fail()
+		appErr := errors.Wrap(err, errors.ErrorTypeInternal, "REQUEST_CREATION_FAILED", "Failed to create request to origin server", http.StatusInternalServerError)
+		s.logger.Error().Err(appErr).Msg("Request creation failed")
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+	req.Header = header
+	if rt.upstream.preserveHost {
+		req.Host = c.Request.Host
+	}
+
+	ticket, allowed := rt.upstream.breaker.acquire()
+	if !allowed {
+		handshake.Stop()
+		s.upgrades.fail()
+		appErr := errors.ErrCircuitOpen
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	// Go straight to the transport: a protocol switch is answered by the
+	// target itself, never by following a redirect
+	start := time.Now()
+	done := member.Start()
+	resp, err := rt.upstream.client.Transport.RoundTrip(req)
+	handshake.Stop()
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	done(time.Since(start), failed)
+	ticket.done(time.Since(start), failed)
+	rt.upstream.health.Observe(member, failed)
+	if err != nil {
+		s.upgrades.fail()
+		appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "ORIGIN_REQUEST_FAILED", "Failed to reach origin server", http.StatusBadGateway)
+		if isTimeout(err) || ctx.Err() != nil {
+			appErr = errors.Wrap(err, errors.ErrorTypeTimeout, "ORIGIN_TIMEOUT", "Origin server did not respond in time", http.StatusGatewayTimeout)
+		}
+		s.logger.Error().Err(appErr).Str("route", rt.name).Str("upstream", rt.upstream.name).Str("target", member.URL.String()).Msg("Upgrade request failed")
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// The origin declined to switch; pass its answer on as is
+		defer resp.Body.Close()
+		removeHopHeaders(resp.Header)
+		applyHeaderRules(resp.Header, rt.upstream.responseHeaders)
+		for key, values := range resp.Header {
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
+		c.Status(resp.StatusCode)
+		io.Copy(c.Writer, resp.Body)
+		return
+	}
+
+	backend, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		s.upgrades.fail()
+		appErr := errors.New(errors.ErrorTypeNetwork, "UPGRADE_FAILED", "Origin connection does not support protocol switching", http.StatusBadGateway)
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+
+	// Record the switch for the access log; nothing is written until the
+	// hijacked connection is handed the origin's response below
+	c.Status(http.StatusSwitchingProtocols)
+	client, buffered, err := c.Writer.Hijack()
+	if err != nil {
+		backend.Close()
+		s.upgrades.fail()
+		appErr := errors.Wrap(err, errors.ErrorTypeInternal, "UPGRADE_NOT_SUPPORTED", "Connection cannot be upgraded", http.StatusInternalServerError)
+		s.logger.Error().Err(appErr).Str("route", rt.name).Msg("Failed to hijack client connection")
+		c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+		return
+	}
+	// The server's read and write timeouts are meant for requests, not for
+	// long-lived connections; the idle timeout takes over from here
+	client.SetDeadline(time.Time{})
+
+	// Relay the origin's 101 with the headers that make the switch
+	respHeader := resp.Header.Clone()
+	removeHopHeaders(respHeader)
+	applyHeaderRules(respHeader, rt.upstream.responseHeaders)
+	respHeader.Set("Connection", "Upgrade")
+	respHeader.Set("Upgrade", resp.Header.Get("Upgrade"))
+	buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	respHeader.Write(buffered)
+	buffered.WriteString("\r\n")
+	if err := buffered.Flush(); err != nil {
+		client.Close()
+		backend.Close()
+		s.upgrades.fail()
+		s.logger.Error().Err(err).Str("route", rt.name).Msg("Failed to send upgrade response")
+		return
+	}
+
+	s.logger.Info().
+		Str("route", rt.name).
+		Str("upstream", rt.upstream.name).
+		Str("target", member.URL.String()).
+		Str("protocol", protocol).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Connection upgraded")
+
+	s.upgrades.run(&splice{client: client, backend: backend}, buffered)
+
+	s.logger.Debug().
+		Str("route", rt.name).
+		Str("protocol", protocol).
+		Dur("duration", time.Since(start)).
+		Str("request_id", c.GetString("request_id")).
+		Msg("Upgraded connection closed")
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promoteRequest is the body accepted by POST /cache/versions/promote
+type promoteRequest struct {
+	URL     string `json:"url" binding:"required"`
+	Host    string `json:"host"` // required unless url is absolute
+	Method  string `json:"method"`
+	Version int64  `json:"version" binding:"required"`
+	Freeze  bool   `json:"freeze"`
+}
+
+// handleListVersions returns the current and retained versions cached for
+// ?url= (with ?host= unless it is absolute), newest first
+func (s *Server) handleListVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		z, cacheKey, appErr := s.resolveEntryURL(c.Query("url"), c.Query("host"), c.Query("method"))
+		if appErr != nil {
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		versions := z.cache.Versions(cacheKey)
+		if len(versions) == 0 {
+			appErr := errors.New(errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached versions for URL", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		for i := range versions {
+			versions[i].Zone = z.name
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"key":       cacheKey,
+			"versions":  versions,
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// handlePromoteVersion rolls a URL back to one of its retained versions
+func (s *Server) handlePromoteVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req promoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_PROMOTE", "Request body must be JSON with url and version fields", http.StatusBadRequest)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		z, cacheKey, appErr := s.resolveEntryURL(req.URL, req.Host, req.Method)
+		if appErr != nil {
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		if err := z.cache.Promote(cacheKey, req.Version, req.Freeze); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeCacheFailure, "PROMOTE_FAILED", "Failed to promote version", http.StatusInternalServerError)
+			if stderrors.Is(err, cache.ErrVersionNotFound) {
+				appErr = errors.Wrap(err, errors.ErrorTypeNotFound, "VERSION_NOT_FOUND", "No retained version with that number for URL", http.StatusNotFound)
+			} else if stderrors.Is(err, cache.ErrVersionBanned) {
+				appErr = errors.Wrap(err, errors.ErrorTypeValidation, "VERSION_BANNED", "Version is covered by an active ban and cannot be promoted", http.StatusConflict)
+			}
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		s.logger.Warn().
+			Str("url", req.URL).
+			Str("zone", z.name).
+			Str("cache_key", cacheKey).
+			Int64("version", req.Version).
+			Bool("frozen", req.Freeze).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Cache entry rolled back to an older version")
+
+		c.JSON(http.StatusOK, gin.H{
+			"key":       cacheKey,
+			"versions":  z.cache.Versions(cacheKey),
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// handleFreezeEntry freezes or unfreezes the entry stored under :key
+func (s *Server) handleFreezeEntry(frozen bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		z, _, exists := s.findEntry(key)
+		if !exists {
+			appErr := errors.New(errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached entry for key", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		if err := z.cache.Freeze(key, frozen); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeNotFound, "ENTRY_NOT_FOUND", "No cached entry for key", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		s.logger.Info().
+			Str("zone", z.name).
+			Str("cache_key", key).
+			Bool("frozen", frozen).
+			Str("request_id", c.GetString("request_id")).
+			Msg("Cache entry freeze updated")
+
+		c.JSON(http.StatusOK, gin.H{
+			"key":       key,
+			"frozen":    frozen,
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// resolveEntryURL returns the zone and cache key a request for rawURL on
+// host with method (GET by default) would use
+func (s *Server) resolveEntryURL(rawURL, host, method string) (*zone, string, *errors.AppError) {
+	target, appErr := parseEntryURL(rawURL, host, "INVALID_URL")
+	if appErr != nil {
+		return nil, "", appErr
+	}
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	rt, ok := s.routeFor(target.Host, target.Path)
+	if !ok {
+		return nil, "", errors.New(errors.ErrorTypeNotFound, "ROUTE_NOT_FOUND", "No route matches the URL", http.StatusNotFound)
+	}
+
+	z := s.zoneForRoute(rt, target.Host, target.Path)
+	return z, z.cache.GenerateKey(rt.name, method, target.Host, target.Path, target.RawQuery), nil
+}
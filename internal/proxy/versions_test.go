@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/config"
+)
+
+// TestVersionsOfProxiedEntry lists and promotes versions stored by client
+// requests, addressing them by path and host as well as by absolute URL
+func TestVersionsOfProxiedEntry(t *testing.T) {
+	var served atomic.Int64
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "v%d", served.Add(1))
+	})
+	s := newTestServer(t, origin, func(cfg *config.Config) {
+		cfg.CacheTTL = 20 * time.Millisecond
+	})
+
+	serve(s, http.MethodGet, "site.test", "/page", "")
+	time.Sleep(30 * time.Millisecond)
+	if rec := serve(s, http.MethodGet, "site.test", "/page", ""); rec.Body.String() != "v2" {
+		t.Fatalf("second fetch = %q, want v2", rec.Body.String())
+	}
+
+	if rec := serve(s, http.MethodGet, "site.test", "/cache/versions?url=/page", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("listing a path without a host = %d, want 400", rec.Code)
+	}
+
+	var listed struct {
+		Versions []cache.EntryInfo `json:"versions"`
+	}
+	for _, query := range []string{"url=/page&host=SITE.test:8080", "url=http://site.test/page"} {
+		rec := serve(s, http.MethodGet, "site.test", "/cache/versions?"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s = %d: %s", query, rec.Code, rec.Body)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+			t.Fatalf("decode versions: %v", err)
+		}
+		if len(listed.Versions) != 2 {
+			t.Fatalf("list %s returned %d versions, want 2", query, len(listed.Versions))
+		}
+	}
+
+	body := fmt.Sprintf(`{"url":"/page","host":"site.test","version":%d,"freeze":true}`, listed.Versions[1].Version)
+	if rec := serve(s, http.MethodPost, "site.test", "/cache/versions/promote", body); rec.Code != http.StatusOK {
+		t.Fatalf("promote = %d: %s", rec.Code, rec.Body)
+	}
+	rec := serve(s, http.MethodGet, "site.test", "/page", "")
+	if rec.Body.String() != "v1" || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("after promotion got %q (X-Cache %s), want cached v1", rec.Body.String(), rec.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cache-proxy/internal/errors"
+	"cache-proxy/internal/warmer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// warmRequest is the body accepted by POST /cache/warm
+type warmRequest struct {
+	URLs        []string `json:"urls"`
+	Sitemap     string   `json:"sitemap"`
+	Concurrency int      `json:"concurrency"`
+	Rate        *float64 `json:"rate"`
+	Host        string   `json:"host"` // for urls given as paths; defaults to the configured warm host
+}
+
+// warmOnBoot starts warming jobs for the URL file and sitemap from config
+func (s *Server) warmOnBoot() {
+	opts := warmer.Options{Concurrency: s.config.WarmConcurrency, Rate: s.config.WarmRate, Host: s.config.WarmHost}
+
+	if s.config.WarmURLsFile != "" {
+		urls, err := warmer.LoadURLFile(s.config.WarmURLsFile)
+		if err != nil {
+			s.logger.Error().Err(err).Str("file", s.config.WarmURLsFile).Msg("Failed to load cache warming URLs")
+		} else {
+			s.warmer.Start(s.config.WarmURLsFile, urls, opts)
+		}
+	}
+
+	if s.config.WarmSitemap != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		urls, err := s.warmer.LoadSitemap(ctx, s.config.WarmSitemap)
+		if err != nil {
+			s.logger.Error().Err(err).Str("sitemap", s.config.WarmSitemap).Msg("Failed to load cache warming sitemap")
+		} else {
+			s.warmer.Start(s.config.WarmSitemap, urls, opts)
+		}
+	}
+}
+
+// handleStartWarm starts a warming job from URLs and/or a remote sitemap in the request body
+func (s *Server) handleStartWarm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req warmRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_WARM_REQUEST", "Request body must be JSON with urls or sitemap", http.StatusBadRequest)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		// An omitted rate uses the configured one; 0 is unlimited
+		rate := s.config.WarmRate
+		if req.Rate != nil {
+			if *req.Rate < 0 {
+				appErr := errors.New(errors.ErrorTypeValidation, "INVALID_WARM_REQUEST", "rate must not be negative", http.StatusBadRequest)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			rate = *req.Rate
+		}
+
+		urls, err := warmer.Normalize(req.URLs)
+		if err != nil {
+			appErr := errors.Wrap(err, errors.ErrorTypeValidation, "INVALID_WARM_REQUEST", err.Error(), http.StatusBadRequest)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		source := "api"
+		if req.Sitemap != "" {
+			// Only remote sitemaps are accepted over the API so it can't read local files
+			if !warmer.IsRemote(req.Sitemap) {
+				appErr := errors.New(errors.ErrorTypeValidation, "INVALID_WARM_REQUEST", "sitemap must be an http(s) URL", http.StatusBadRequest)
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+
+			sitemapURLs, err := s.warmer.LoadSitemap(c.Request.Context(), req.Sitemap)
+			if err != nil {
+				appErr := errors.Wrap(err, errors.ErrorTypeNetwork, "SITEMAP_FETCH_FAILED", "Failed to load sitemap", http.StatusBadGateway)
+				s.logger.Error().Err(appErr).Str("sitemap", req.Sitemap).Msg("Failed to load cache warming sitemap")
+				c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+				return
+			}
+			urls = append(urls, sitemapURLs...)
+			source = req.Sitemap
+		}
+
+		if len(urls) == 0 {
+			appErr := errors.New(errors.ErrorTypeValidation, "INVALID_WARM_REQUEST", "No URLs to warm", http.StatusBadRequest)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+
+		job := s.warmer.Start(source, urls, warmer.Options{Concurrency: req.Concurrency, Rate: rate, Host: req.Host})
+		c.JSON(http.StatusAccepted, gin.H{"job": job.Progress()})
+	}
+}
+
+// handleListWarm returns progress for recent warming jobs
+func (s *Server) handleListWarm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"jobs":      s.warmer.Jobs(),
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// handleGetWarm returns progress and failures for a single warming job
+func (s *Server) handleGetWarm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, exists := s.warmer.Job(c.Param("id"))
+		if !exists {
+			appErr := errors.New(errors.ErrorTypeNotFound, "WARM_JOB_NOT_FOUND", "No warming job with that ID", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"job": job.Progress()})
+	}
+}
+
+// handleCancelWarm cancels a running warming job
+func (s *Server) handleCancelWarm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, exists := s.warmer.Job(c.Param("id"))
+		if !exists {
+			appErr := errors.New(errors.ErrorTypeNotFound, "WARM_JOB_NOT_FOUND", "No warming job with that ID", http.StatusNotFound)
+			c.JSON(appErr.HTTPStatus, gin.H{"error": appErr.Message, "code": appErr.Code})
+			return
+		}
+		job.Cancel()
+		c.JSON(http.StatusOK, gin.H{"job": job.Progress()})
+	}
+}
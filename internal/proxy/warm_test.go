@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cache-proxy/internal/config"
+	"cache-proxy/internal/warmer"
+)
+
+// waitForJob waits until a warming job has finished and returns its progress
+func waitForJob(t *testing.T, job *warmer.Job) warmer.Progress {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		progress := job.Progress()
+		if progress.Status != warmer.StatusRunning {
+			return progress
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("warming job did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestWarmedURLsAreHits checks that warmed absolute URLs and paths are
+// cached under the keys client requests for them use, and that paths are
+// refused when there is no host to warm them for
+func TestWarmedURLsAreHits(t *testing.T) {
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	s := newTestServer(t, origin, func(cfg *config.Config) {
+		cfg.WarmHost = "site.test"
+	})
+
+	progress := waitForJob(t, s.warmer.Start("test", []string{"http://other.test/a", "/b"}, warmer.Options{}))
+	if progress.Succeeded != 2 {
+		t.Fatalf("succeeded = %d, want 2: %+v", progress.Succeeded, progress.Failures)
+	}
+	for _, tt := range []struct{ host, path string }{{"other.test", "/a"}, {"site.test:8080", "/b"}} {
+		if rec := serve(s, http.MethodGet, tt.host, tt.path, ""); rec.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("GET %s%s after warming: X-Cache = %q, want HIT", tt.host, tt.path, rec.Header().Get("X-Cache"))
+		}
+	}
+
+	s.warmer.Close()
+	s.warmer = warmer.New(s.router, s.logger, warmer.Options{})
+	progress = waitForJob(t, s.warmer.Start("test", []string{"/c"}, warmer.Options{}))
+	if progress.Failed != 1 || progress.Succeeded != 0 {
+		t.Errorf("path without a host: succeeded = %d, failed = %d; want 0 and 1", progress.Succeeded, progress.Failed)
+	}
+}
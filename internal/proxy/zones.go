@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"cache-proxy/internal/cache"
+	"cache-proxy/internal/config"
+)
+
+// defaultZoneName is the zone that takes every request no other zone matches
+const defaultZoneName = "default"
+
+// zone is a named cache with its own status policy and routing rules
+type zone struct {
+	name       string
+	cache      cache.Cache
+	policy     cache.StatusPolicy
+	hosts      []string
+	prefixes   []string
+	extensions []string
+}
+
+// newZone creates the cache for a configured zone. Unset sizes and policies
+// fall back to the proxy-wide settings.
+func newZone(zc config.ZoneConfig, cfg *config.Config, base cache.StatusPolicy) *zone {
+	maxSize := zc.MaxSize
+	if maxSize == 0 {
+		maxSize = cfg.CacheSize
+	}
+	admission := zc.Admission
+	if admission == "" {
+		admission = cfg.CacheAdmission
+	}
+	namespace := zc.Namespace
+	if namespace == "" {
+		namespace = cfg.CacheNamespace
+	}
+	eviction := zc.Eviction
+	if eviction == "" {
+		eviction = cache.EvictionFIFO
+	}
+
+	policy := base
+	if zc.TTL > 0 {
+		policy.SuccessTTL = time.Duration(zc.TTL)
+	}
+
+	z := &zone{
+		name: zc.Name,
+		cache: cache.New(cache.Config{
+			MaxSize:         maxSize,
+			DefaultTTL:      policy.SuccessTTL,
+			CleanupInterval: cfg.CleanupInterval,
+			Admission:       admission,
+			Eviction:        eviction,
+			Versions:        cfg.CacheVersions,
+			Namespace:       namespace,
+			StaleGrace:      cfg.StaleGrace,
+		}),
+		policy: policy,
+	}
+	for _, host := range zc.Hosts {
+		z.hosts = append(z.hosts, strings.ToLower(host))
+	}
+	z.prefixes = zc.PathPrefixes
+	for _, ext := range zc.Extensions {
+		z.extensions = append(z.extensions, strings.ToLower("."+strings.TrimPrefix(ext, ".")))
+	}
+	return z
+}
+
+// matches reports whether a request for host and path is routed to this zone
+func (z *zone) matches(host, requestPath string) bool {
+	if len(z.hosts) > 0 && !hostMatches(z.hosts, host) {
+		return false
+	}
+
+	if len(z.prefixes) == 0 && len(z.extensions) == 0 {
+		return true
+	}
+	for _, prefix := range z.prefixes {
+		if pathHasPrefix(requestPath, prefix) {
+			return true
+		}
+	}
+	return containsString(z.extensions, strings.ToLower(path.Ext(requestPath)))
+}
+
+// zoneFor returns the first zone whose rules match the request, falling
+// back to the default zone
+func (s *Server) zoneFor(host, requestPath string) *zone {
+	for _, z := range s.zones[:len(s.zones)-1] {
+		if z.matches(host, requestPath) {
+			return z
+		}
+	}
+	return s.defaultZone()
+}
+
+// defaultZone returns the catch-all zone, which is always last
+func (s *Server) defaultZone() *zone {
+	return s.zones[len(s.zones)-1]
+}
+
+// zoneByName returns the zone with the given name
+func (s *Server) zoneByName(name string) (*zone, bool) {
+	for _, z := range s.zones {
+		if z.name == name {
+			return z, true
+		}
+	}
+	return nil, false
+}
+
+// findEntry looks up key in every zone without affecting stats
+func (s *Server) findEntry(key string) (*zone, *cache.Entry, bool) {
+	for _, z := range s.zones {
+		if entry, exists := z.cache.Peek(key); exists {
+			return z, entry, true
+		}
+	}
+	return nil, nil, false
+}
+
+// caches returns the cache of every zone
+func (s *Server) caches() []cache.Cache {
+	caches := make([]cache.Cache, len(s.zones))
+	for i, z := range s.zones {
+		caches[i] = z.cache
+	}
+	return caches
+}
+
+// zoneStats returns per-zone stats and their sum
+func (s *Server) zoneStats() (map[string]cache.Stats, cache.Stats) {
+	perZone := make(map[string]cache.Stats, len(s.zones))
+	var total cache.Stats
+	for _, z := range s.zones {
+		stats := z.cache.Stats()
+		perZone[z.name] = stats
+
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Size += stats.Size
+		total.Evictions += stats.Evictions
+		total.AdmissionRejections += stats.AdmissionRejections
+		total.Bytes += stats.Bytes
+		total.PressureEvictions += stats.PressureEvictions
+		total.BodyBytes += stats.BodyBytes
+		total.StoredBodyBytes += stats.StoredBodyBytes
+		total.UniqueBodies += stats.UniqueBodies
+		if stats.LastCleared.After(total.LastCleared) {
+			total.LastCleared = stats.LastCleared
+		}
+	}
+
+	// Zones dedupe independently, so the overall ratio comes from the sums
+	total.DedupeRatio = 1
+	if total.StoredBodyBytes > 0 {
+		total.DedupeRatio = float64(total.BodyBytes) / float64(total.StoredBodyBytes)
+	}
+	return perZone, total
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package warmer
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxSitemapDepth bounds how many levels of sitemap indexes are followed
+const maxSitemapDepth = 2
+
+// sitemapDocument covers both <urlset> sitemaps and <sitemapindex> indexes
+type sitemapDocument struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// LoadURLFile reads one URL or path per line, skipping blanks and # comments
+func LoadURLFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return Normalize(lines)
+}
+
+// LoadSitemap reads a sitemap.xml from an http(s) URL or a local file,
+// following sitemap indexes, and returns the listed URLs
+func (w *Warmer) LoadSitemap(ctx context.Context, location string) ([]string, error) {
+	locations, err := w.loadSitemap(ctx, location, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Normalize(locations)
+}
+
+// loadSitemap fetches and parses a single sitemap document
+func (w *Warmer) loadSitemap(ctx context.Context, location string, depth int) ([]string, error) {
+	reader, err := w.openSitemap(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse sitemap %s: %w", location, err)
+	}
+
+	locations := make([]string, 0, len(doc.URLs))
+	for _, u := range doc.URLs {
+		locations = append(locations, u.Loc)
+	}
+
+	if depth < maxSitemapDepth {
+		for _, child := range doc.Sitemaps {
+			childLocation := strings.TrimSpace(child.Loc)
+			// A remote sitemap must not point the warmer at local files
+			if IsRemote(location) && !IsRemote(childLocation) {
+				return nil, fmt.Errorf("sitemap %s: nested sitemap %q is not an http(s) URL", location, childLocation)
+			}
+			nested, err := w.loadSitemap(ctx, childLocation, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			locations = append(locations, nested...)
+		}
+	}
+
+	return locations, nil
+}
+
+// openSitemap opens a sitemap from the network or the filesystem
+func (w *Warmer) openSitemap(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !IsRemote(location) {
+		return os.Open(location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch sitemap %s: unexpected status %d", location, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// IsRemote reports whether a location is an http(s) URL rather than a file path
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// Normalize trims and validates URLs and paths. Absolute URLs keep their
+// host, which the request is sent for; a path is sent without one.
+func Normalize(locations []string) ([]string, error) {
+	targets := make([]string, 0, len(locations))
+	for _, location := range locations {
+		location = strings.TrimSpace(location)
+		if location == "" {
+			continue
+		}
+		parsed, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", location, err)
+		}
+		if parsed.Host != "" {
+			parsed.Fragment = ""
+			targets = append(targets, parsed.String())
+		} else {
+			targets = append(targets, parsed.RequestURI())
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,375 @@
+package warmer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cache-proxy/internal/logger"
+)
+
+const (
+	// maxFailures caps how many individual failures a job keeps for its summary
+	maxFailures = 100
+	// maxJobs caps how many finished jobs are retained for progress queries
+	maxJobs = 20
+	// AdminPrefix is where the proxy serves its admin API, which is never warmed
+	AdminPrefix = "/cache"
+)
+
+// Job states
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// Options controls how aggressively a job warms the cache
+type Options struct {
+	Concurrency int     `json:"concurrency"`
+	Rate        float64 `json:"rate"` // requests per second, 0 for unlimited
+	Host        string  `json:"host"` // for URLs given as paths
+}
+
+// Failure describes a URL that could not be warmed
+type Failure struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Progress is a point-in-time snapshot of a warming job
+type Progress struct {
+	ID         string     `json:"id"`
+	Source     string     `json:"source"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Completed  int64      `json:"completed"`
+	Succeeded  int64      `json:"succeeded"`
+	Failed     int64      `json:"failed"`
+	Hits       int64      `json:"hits"`
+	Failures   []Failure  `json:"failures"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Options    Options    `json:"options"`
+}
+
+// Job is a running or finished warming job
+type Job struct {
+	id        string
+	source    string
+	urls      []string
+	options   Options
+	startedAt time.Time
+	cancel    context.CancelFunc
+
+	completed int64
+	succeeded int64
+	failed    int64
+	hits      int64
+
+	mutex      sync.Mutex
+	status     string
+	failures   []Failure
+	finishedAt *time.Time
+}
+
+// Progress returns a snapshot of the job's progress
+func (j *Job) Progress() Progress {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	failures := make([]Failure, len(j.failures))
+	copy(failures, j.failures)
+
+	return Progress{
+		ID:         j.id,
+		Source:     j.source,
+		Status:     j.status,
+		Total:      len(j.urls),
+		Completed:  atomic.LoadInt64(&j.completed),
+		Succeeded:  atomic.LoadInt64(&j.succeeded),
+		Failed:     atomic.LoadInt64(&j.failed),
+		Hits:       atomic.LoadInt64(&j.hits),
+		Failures:   failures,
+		StartedAt:  j.startedAt,
+		FinishedAt: j.finishedAt,
+		Options:    j.options,
+	}
+}
+
+// Cancel stops the job; URLs already in flight are allowed to finish
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// recordFailure counts a failed URL and keeps it for the summary
+func (j *Job) recordFailure(failure Failure) {
+	atomic.AddInt64(&j.failed, 1)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if len(j.failures) < maxFailures {
+		j.failures = append(j.failures, failure)
+	}
+}
+
+// finish marks the job as completed or cancelled
+func (j *Job) finish(cancelled bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.status = StatusCompleted
+	if cancelled {
+		j.status = StatusCancelled
+	}
+	finishedAt := time.Now()
+	j.finishedAt = &finishedAt
+}
+
+// Warmer replays URL lists through the proxy's own handler so responses
+// land in the cache exactly as they would for a client request
+type Warmer struct {
+	handler  http.Handler
+	logger   logger.Logger
+	defaults Options
+	client   *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex  sync.Mutex
+	jobs   map[string]*Job
+	order  []string
+	nextID int64
+}
+
+// New creates a warmer that sends requests to handler
+func New(handler http.Handler, log logger.Logger, defaults Options) *Warmer {
+	if defaults.Concurrency <= 0 {
+		defaults.Concurrency = 4
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Warmer{
+		handler:  handler,
+		logger:   log,
+		defaults: defaults,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*Job),
+	}
+}
+
+// Start launches a warming job in the background and returns it immediately.
+// A zero concurrency and an empty host fall back to the warmer's defaults;
+// a zero rate is unlimited.
+func (w *Warmer) Start(source string, urls []string, opts Options) *Job {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = w.defaults.Concurrency
+	}
+	if opts.Host == "" {
+		opts.Host = w.defaults.Host
+	}
+	if opts.Rate < 0 {
+		opts.Rate = 0
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+
+	w.mutex.Lock()
+	w.nextID++
+	job := &Job{
+		id:        fmt.Sprintf("warm-%d", w.nextID),
+		source:    source,
+		urls:      urls,
+		options:   opts,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		status:    StatusRunning,
+	}
+	w.jobs[job.id] = job
+	w.order = append(w.order, job.id)
+	if len(w.order) > maxJobs {
+		delete(w.jobs, w.order[0])
+		w.order = w.order[1:]
+	}
+	w.mutex.Unlock()
+
+	w.logger.Info().
+		Str("job_id", job.id).
+		Str("source", source).
+		Int("urls", len(urls)).
+		Int("concurrency", opts.Concurrency).
+		Float64("rate", opts.Rate).
+		Msg("Cache warming started")
+
+	go w.run(ctx, job)
+	return job
+}
+
+// Job returns the job with the given ID
+func (w *Warmer) Job(id string) (*Job, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	job, exists := w.jobs[id]
+	return job, exists
+}
+
+// Jobs returns progress for all retained jobs, oldest first
+func (w *Warmer) Jobs() []Progress {
+	w.mutex.Lock()
+	jobs := make([]*Job, 0, len(w.order))
+	for _, id := range w.order {
+		jobs = append(jobs, w.jobs[id])
+	}
+	w.mutex.Unlock()
+
+	progress := make([]Progress, 0, len(jobs))
+	for _, job := range jobs {
+		progress = append(progress, job.Progress())
+	}
+	return progress
+}
+
+// Close cancels all running jobs
+func (w *Warmer) Close() {
+	w.cancel()
+}
+
+// run dispatches the job's URLs to a bounded pool of workers at the configured rate
+func (w *Warmer) run(ctx context.Context, job *Job) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+
+	for i := 0; i < job.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				w.warm(ctx, job, target)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if job.options.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / job.options.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+dispatch:
+	for _, target := range job.urls {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case queue <- target:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	job.finish(ctx.Err() != nil)
+	job.cancel()
+
+	progress := job.Progress()
+	w.logger.Info().
+		Str("job_id", job.id).
+		Str("status", progress.Status).
+		Int64("succeeded", progress.Succeeded).
+		Int64("failed", progress.Failed).
+		Dur("duration", time.Since(progress.StartedAt)).
+		Msg("Cache warming finished")
+}
+
+// warm sends a single GET through the handler and records the outcome. The
+// request carries the URL's host, or the job's for a path, so it is routed
+// and cached as a client's request for it would be. Paths without a host
+// would be cached under a key no client uses, so they fail instead.
+func (w *Warmer) warm(ctx context.Context, job *Job, target string) {
+	defer atomic.AddInt64(&job.completed, 1)
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		job.recordFailure(Failure{URL: target, Error: err.Error()})
+		return
+	}
+	if isAdminPath(parsed.Path) {
+		job.recordFailure(Failure{URL: target, Error: "admin paths cannot be warmed"})
+		return
+	}
+	host := parsed.Host
+	if host == "" {
+		host = job.options.Host
+	}
+	if host == "" {
+		job.recordFailure(Failure{URL: target, Error: "no host: use an absolute URL or set a warm host"})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.RequestURI(), nil)
+	if err != nil {
+		job.recordFailure(Failure{URL: target, Error: err.Error()})
+		return
+	}
+	req.Host = host
+	req.Header.Set("X-Cache-Warmer", job.id)
+
+	rec := newDiscardWriter()
+	w.handler.ServeHTTP(rec, req)
+
+	if rec.status >= http.StatusBadRequest {
+		job.recordFailure(Failure{URL: target, Status: rec.status})
+		return
+	}
+
+	atomic.AddInt64(&job.succeeded, 1)
+	if rec.header.Get("X-Cache") == "HIT" {
+		atomic.AddInt64(&job.hits, 1)
+	}
+}
+
+// isAdminPath reports whether path is under the admin API
+func isAdminPath(path string) bool {
+	return path == AdminPrefix || strings.HasPrefix(path, AdminPrefix+"/")
+}
+
+// discardWriter is a ResponseWriter that keeps the status and headers and drops the body
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func newDiscardWriter() *discardWriter {
+	return &discardWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d *discardWriter) WriteHeader(status int) {
+	d.status = status
+}
+
+// Flush does nothing; it lets streamed responses be warmed
+func (d *discardWriter) Flush() {}